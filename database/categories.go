@@ -0,0 +1,152 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+)
+
+// OtherCategorySlug is where free-text categories that match nothing in the
+// taxonomy end up.
+const OtherCategorySlug = "other"
+
+// taxonomy is the controlled category list. Parents come before their
+// children, and position follows slice order. Aliases are the spellings
+// seen in free-text categories that should map onto each entry.
+var taxonomy = []struct {
+	slug    string
+	name    string
+	parent  string
+	aliases []string
+}{
+	{"home", "Home", "", []string{"home and garden", "household"}},
+	{"furniture", "Furniture", "home", nil},
+	{"kitchen", "Kitchen", "home", []string{"kitchenware", "cookware"}},
+	{"electronics", "Electronics", "", []string{"electronic", "tech", "gadgets"}},
+	{"clothing", "Clothing", "", []string{"clothes", "fashion", "apparel"}},
+	{"sports", "Sports", "", []string{"sport", "outdoors", "fitness"}},
+	{"books", "Books", "", []string{"book"}},
+	{"music", "Music", "", nil},
+	{"musical-instruments", "Musical Instruments", "music", []string{"instruments", "instrument"}},
+	{"toys-games", "Toys & Games", "", []string{"toys", "games", "board games"}},
+	{OtherCategorySlug, "Other", "", nil},
+}
+
+func (db *DB) seedCategories() error {
+	for i, c := range taxonomy {
+		var parent interface{}
+		if c.parent != "" {
+			parent = c.parent
+		}
+		_, err := db.Exec(`
+			INSERT OR IGNORE INTO categories (slug, name, parent_id, position)
+			VALUES (?, ?, (SELECT id FROM categories WHERE slug = ?), ?)
+		`, c.slug, c.name, parent, i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeCategory folds case, "&" and punctuation so that "Toys & Games",
+// "toys and games" and "TOYS-AND-GAMES" compare equal.
+func normalizeCategory(s string) string {
+	s = strings.ReplaceAll(strings.ToLower(s), "&", " and ")
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// categoryAliases maps every normalized spelling in the taxonomy to its slug.
+func categoryAliases() map[string]string {
+	aliases := map[string]string{}
+	for _, c := range taxonomy {
+		aliases[normalizeCategory(c.slug)] = c.slug
+		aliases[normalizeCategory(c.name)] = c.slug
+		for _, a := range c.aliases {
+			aliases[normalizeCategory(a)] = c.slug
+		}
+	}
+	return aliases
+}
+
+// knownAliases is categoryAliases, built once for CategorySlug.
+var knownAliases = categoryAliases()
+
+// CategorySlug returns the slug of the taxonomy entry that ref names, by
+// slug, display name or alias, the same way existing free-text categories
+// are mapped. It reports false when nothing matches.
+func CategorySlug(ref string) (string, bool) {
+	slug, ok := knownAliases[normalizeCategory(ref)]
+	return slug, ok
+}
+
+// mapItemCategories assigns a category_id to items that only have free-text
+// categories, rewriting the text to the category's display name. Values that
+// match nothing go to "Other"; for those an item_revisions row keeps the
+// original text so the owner can recategorize.
+func (db *DB) mapItemCategories() error {
+	rows, err := db.Query(`
+		SELECT id, user_id, category FROM items
+		WHERE category_id IS NULL AND TRIM(COALESCE(category, '')) != ''
+	`)
+	if err != nil {
+		return err
+	}
+
+	type pending struct {
+		id, userID int
+		category   string
+	}
+	var items []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.userID, &p.category); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	aliases := categoryAliases()
+	for _, item := range items {
+		slug, ok := aliases[normalizeCategory(item.category)]
+		if !ok {
+			slug = OtherCategorySlug
+		}
+
+		var id int
+		var name string
+		if err := tx.QueryRow("SELECT id, name FROM categories WHERE slug = ?", slug).Scan(&id, &name); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("UPDATE items SET category_id = ?, category = ? WHERE id = ?", id, name, item.id); err != nil {
+			return err
+		}
+
+		if !ok {
+			if _, err := tx.Exec(
+				"INSERT INTO item_revisions (item_id, user_id, field, old_value, new_value) VALUES (?, ?, ?, ?, ?)",
+				item.id, item.userID, "category", item.category, name,
+			); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
@@ -45,6 +45,15 @@ func (db *DB) Init() error {
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 
+	CREATE TABLE IF NOT EXISTS categories (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		slug TEXT UNIQUE NOT NULL,
+		name TEXT NOT NULL,
+		parent_id INTEGER,
+		position INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (parent_id) REFERENCES categories(id)
+	);
+
 	CREATE TABLE IF NOT EXISTS items (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		user_id INTEGER NOT NULL,
@@ -90,13 +99,201 @@ func (db *DB) Init() error {
 		FOREIGN KEY (user_id) REFERENCES users(id)
 	);
 
+	CREATE TABLE IF NOT EXISTS item_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		item_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		field TEXT NOT NULL,
+		old_value TEXT,
+		new_value TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS item_photos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		item_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		blob_key TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		width INTEGER NOT NULL,
+		height INTEGER NOT NULL,
+		size_bytes INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS photo_variants (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		photo_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		blob_key TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		width INTEGER NOT NULL,
+		height INTEGER NOT NULL,
+		size_bytes INTEGER NOT NULL,
+		FOREIGN KEY (photo_id) REFERENCES item_photos(id) ON DELETE CASCADE,
+		UNIQUE(photo_id, name)
+	);
+
+	CREATE TABLE IF NOT EXISTS wants (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		keywords TEXT NOT NULL DEFAULT '',
+		category_id INTEGER,
+		max_distance_km REAL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (category_id) REFERENCES categories(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS want_suggestions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		want_id INTEGER NOT NULL,
+		item_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (want_id) REFERENCES wants(id) ON DELETE CASCADE,
+		FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		UNIQUE(want_id, item_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS group_matches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		cycle_key TEXT UNIQUE NOT NULL,
+		status TEXT NOT NULL DEFAULT 'proposed',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS group_match_participants (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_match_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		gives_item_id INTEGER NOT NULL,
+		receives_item_id INTEGER NOT NULL,
+		response TEXT NOT NULL DEFAULT 'pending',
+		responded_at DATETIME,
+		FOREIGN KEY (group_match_id) REFERENCES group_matches(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (gives_item_id) REFERENCES items(id),
+		FOREIGN KEY (receives_item_id) REFERENCES items(id),
+		UNIQUE(group_match_id, user_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS blocks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		blocker_id INTEGER NOT NULL,
+		blocked_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (blocker_id) REFERENCES users(id),
+		FOREIGN KEY (blocked_id) REFERENCES users(id),
+		UNIQUE(blocker_id, blocked_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS super_like_usage (
+		user_id INTEGER NOT NULL,
+		day TEXT NOT NULL,
+		used INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (user_id, day),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		actor_id INTEGER NOT NULL,
+		item_id INTEGER NOT NULL,
+		read_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (actor_id) REFERENCES users(id),
+		FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS item_similarities (
+		item_id INTEGER NOT NULL,
+		similar_item_id INTEGER NOT NULL,
+		score REAL NOT NULL,
+		PRIMARY KEY (item_id, similar_item_id),
+		FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE,
+		FOREIGN KEY (similar_item_id) REFERENCES items(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS sessions (
+		id TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip_address TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		revoked_at DATETIME,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		family_id TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		revoked_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS password_resets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS email_verifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_comments_match_id ON comments(match_id);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+	CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+	CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_blocks_blocked_id ON blocks(blocked_id);
+	CREATE INDEX IF NOT EXISTS idx_group_match_participants_user ON group_match_participants(user_id);
+	CREATE INDEX IF NOT EXISTS idx_wants_user_id ON wants(user_id);
+	CREATE INDEX IF NOT EXISTS idx_want_suggestions_user_id ON want_suggestions(user_id, item_id);
+	CREATE INDEX IF NOT EXISTS idx_item_photos_item_id ON item_photos(item_id, position);
+	CREATE INDEX IF NOT EXISTS idx_item_revisions_item_id ON item_revisions(item_id);
 	`
 
 		if _, err := db.Exec(schema); err != nil {
 		return fmt.Errorf("failed to create schema: %w", err)
 	}
 
+	if err := db.migrate(); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	if err := db.initSearch(); err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	if err := db.seedCategories(); err != nil {
+		return fmt.Errorf("failed to seed categories: %w", err)
+	}
+
 	// Seed users
 	if err := db.seedUsers(); err != nil {
 		return fmt.Errorf("failed to seed users: %w", err)
@@ -107,6 +304,11 @@ func (db *DB) Init() error {
 		return fmt.Errorf("failed to seed items: %w", err)
 	}
 
+	// Runs after seeding so seeded and pre-taxonomy items are both mapped
+	if err := db.mapItemCategories(); err != nil {
+		return fmt.Errorf("failed to map item categories: %w", err)
+	}
+
 	return nil
 }
 
@@ -125,10 +327,10 @@ func (db *DB) seedUsers() error {
 		charlieHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
 
 		_, err := db.Exec(`
-			INSERT INTO users (name, email, password_hash) VALUES 
-			('Alice', 'alice@example.com', ?),
-			('Bob', 'bob@example.com', ?),
-			('Charlie', 'charlie@example.com', ?)
+			INSERT INTO users (name, email, password_hash, email_verified_at) VALUES 
+			('Alice', 'alice@example.com', ?, CURRENT_TIMESTAMP),
+			('Bob', 'bob@example.com', ?, CURRENT_TIMESTAMP),
+			('Charlie', 'charlie@example.com', ?, CURRENT_TIMESTAMP)
 		`, string(aliceHash), string(bobHash), string(charlieHash))
 		if err != nil {
 			return err
@@ -0,0 +1,109 @@
+package database
+
+import "fmt"
+
+// columnMigrations lists columns added after the original schema. They are
+// applied with ALTER TABLE when missing, so fresh and existing databases end
+// up with the same shape.
+var columnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"items", "status", "TEXT NOT NULL DEFAULT 'available'"},
+	{"matches", "status", "TEXT NOT NULL DEFAULT 'proposed'"},
+	{"items", "condition", "TEXT"},
+	{"items", "estimated_value", "INTEGER"},
+	{"items", "category_id", "INTEGER REFERENCES categories(id)"},
+	{"users", "latitude", "REAL"},
+	{"users", "longitude", "REAL"},
+	{"users", "display_area", "TEXT"},
+	{"items", "latitude", "REAL"},
+	{"items", "longitude", "REAL"},
+	{"items", "display_area", "TEXT"},
+	{"matches", "accepted_at", "DATETIME"},
+	{"matches", "declined_at", "DATETIME"},
+	{"matches", "completed_at", "DATETIME"},
+	{"matches", "cancelled_at", "DATETIME"},
+	{"matches", "user1_confirmed_at", "DATETIME"},
+	{"matches", "user2_confirmed_at", "DATETIME"},
+	{"matches", "archived_at", "DATETIME"},
+	{"comments", "archived_at", "DATETIME"},
+	{"swipes", "idempotency_key", "TEXT"},
+	{"users", "interest_hints", "INTEGER NOT NULL DEFAULT 0"},
+	{"users", "email_verified_at", "DATETIME"},
+	{"group_match_participants", "confirmed_at", "DATETIME"},
+}
+
+// columnBackfills fill in a migrated column, keyed by "table.column". They
+// run only when the column is added, so they never touch rows written
+// afterwards.
+var columnBackfills = map[string]string{
+	// Accounts made before verification existed are trusted as they are
+	"users.email_verified_at": "UPDATE users SET email_verified_at = created_at",
+}
+
+// migrationIndexes depend on migrated columns, so they run after columnMigrations.
+const migrationIndexes = `
+	CREATE INDEX IF NOT EXISTS idx_items_status ON items(status);
+	CREATE INDEX IF NOT EXISTS idx_items_created ON items(created_at, id);
+	CREATE INDEX IF NOT EXISTS idx_items_category_id ON items(category_id);
+	CREATE INDEX IF NOT EXISTS idx_items_location ON items(latitude, longitude);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_swipes_idempotency_key ON swipes(user_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+`
+
+func (db *DB) migrate() error {
+	for _, m := range columnMigrations {
+		exists, err := db.columnExists(m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to add %s.%s: %w", m.table, m.column, err)
+		}
+
+		if backfill, ok := columnBackfills[m.table+"."+m.column]; ok {
+			if _, err := db.Exec(backfill); err != nil {
+				return fmt.Errorf("failed to backfill %s.%s: %w", m.table, m.column, err)
+			}
+		}
+	}
+
+	if _, err := db.Exec(migrationIndexes); err != nil {
+		return fmt.Errorf("failed to create indexes: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB) columnExists(table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal interface{}
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
@@ -0,0 +1,56 @@
+package database
+
+import "fmt"
+
+// searchSchema is an FTS5 index over item text. It is an external-content
+// table, so the text lives only in items and the triggers keep the index in
+// step with every insert, update and delete.
+const searchSchema = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS items_fts USING fts5(
+		title,
+		description,
+		category,
+		content='items',
+		content_rowid='id',
+		tokenize='porter unicode61 remove_diacritics 2'
+	);
+
+	CREATE TRIGGER IF NOT EXISTS items_fts_insert AFTER INSERT ON items BEGIN
+		INSERT INTO items_fts(rowid, title, description, category)
+		VALUES (new.id, new.title, COALESCE(new.description, ''), COALESCE(new.category, ''));
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS items_fts_delete AFTER DELETE ON items BEGIN
+		INSERT INTO items_fts(items_fts, rowid, title, description, category)
+		VALUES ('delete', old.id, old.title, COALESCE(old.description, ''), COALESCE(old.category, ''));
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS items_fts_update AFTER UPDATE OF title, description, category ON items BEGIN
+		INSERT INTO items_fts(items_fts, rowid, title, description, category)
+		VALUES ('delete', old.id, old.title, COALESCE(old.description, ''), COALESCE(old.category, ''));
+		INSERT INTO items_fts(rowid, title, description, category)
+		VALUES (new.id, new.title, COALESCE(new.description, ''), COALESCE(new.category, ''));
+	END;
+`
+
+func (db *DB) initSearch() error {
+	var existed int
+	if err := db.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'items_fts'",
+	).Scan(&existed); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(searchSchema); err != nil {
+		return err
+	}
+
+	// Databases created before search existed need their items indexed once
+	if existed == 0 {
+		if _, err := db.Exec("INSERT INTO items_fts(items_fts) VALUES ('rebuild')"); err != nil {
+			return fmt.Errorf("failed to build search index: %w", err)
+		}
+	}
+
+	return nil
+}
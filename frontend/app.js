@@ -1,13 +1,19 @@
 const CONFIG = {
     API_URL: 'http://localhost:8080',
-    STORAGE_KEY: 'bartr_token'
+    STORAGE_KEY: 'bartr_token',
+    REFRESH_KEY: 'bartr_refresh_token',
+    // Access tokens last 15 minutes; renew them well before that
+    REFRESH_INTERVAL_MS: 10 * 60 * 1000
 };
 
 const state = {
     token: localStorage.getItem(CONFIG.STORAGE_KEY),
+    refreshToken: localStorage.getItem(CONFIG.REFRESH_KEY),
+    refreshTimer: null,
     currentUser: null,
     currentItems: [],
-    currentIndex: 0
+    currentIndex: 0,
+    nextCursor: ''
 };
 
 // Authentication Functions
@@ -48,9 +54,8 @@ async function register(e) {
             return;
         }
 
-        state.token = data.token;
+        saveTokens(data);
         state.currentUser = data.user;
-        localStorage.setItem(CONFIG.STORAGE_KEY, state.token);
         showApp();
     } catch (error) {
         showAuthError('Registration failed. Please try again.');
@@ -76,19 +81,58 @@ async function login(e) {
             return;
         }
 
-        state.token = data.token;
+        saveTokens(data);
         state.currentUser = data.user;
-        localStorage.setItem(CONFIG.STORAGE_KEY, state.token);
         showApp();
     } catch (error) {
         showAuthError('Login failed. Please try again.');
     }
 }
 
+function saveTokens(data) {
+    state.token = data.token;
+    state.refreshToken = data.refresh_token;
+    localStorage.setItem(CONFIG.STORAGE_KEY, state.token);
+    localStorage.setItem(CONFIG.REFRESH_KEY, state.refreshToken);
+}
+
+// Exchanges the refresh token for a new access token. Each refresh token
+// works once, so the new one replaces it.
+async function refreshTokens() {
+    if (!state.refreshToken) return false;
+
+    try {
+        const response = await fetch(`${CONFIG.API_URL}/auth/refresh`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ refresh_token: state.refreshToken })
+        });
+
+        if (!response.ok) return false;
+
+        saveTokens(await response.json());
+        return true;
+    } catch (error) {
+        return false;
+    }
+}
+
 function logout() {
+    if (state.refreshToken) {
+        fetch(`${CONFIG.API_URL}/auth/logout`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ refresh_token: state.refreshToken })
+        }).catch(() => {});
+    }
+
+    clearInterval(state.refreshTimer);
+    state.refreshTimer = null;
     state.token = null;
+    state.refreshToken = null;
     state.currentUser = null;
     localStorage.removeItem(CONFIG.STORAGE_KEY);
+    localStorage.removeItem(CONFIG.REFRESH_KEY);
     document.getElementById('authScreen').classList.remove('hidden');
     document.getElementById('appScreen').classList.add('hidden');
 }
@@ -106,10 +150,17 @@ async function checkAuth() {
     }
 
     try {
-        const response = await fetch(`${CONFIG.API_URL}/me`, {
+        let response = await fetch(`${CONFIG.API_URL}/me`, {
             headers: { 'Authorization': `Bearer ${state.token}` }
         });
 
+        // The access token has probably expired since the last visit
+        if (response.status === 401 && await refreshTokens()) {
+            response = await fetch(`${CONFIG.API_URL}/me`, {
+                headers: { 'Authorization': `Bearer ${state.token}` }
+            });
+        }
+
         if (!response.ok) {
             logout();
             return;
@@ -126,9 +177,57 @@ function showApp() {
     document.getElementById('authScreen').classList.add('hidden');
     document.getElementById('appScreen').classList.remove('hidden');
     document.getElementById('userName').textContent = state.currentUser.name;
+    clearInterval(state.refreshTimer);
+    state.refreshTimer = setInterval(refreshTokens, CONFIG.REFRESH_INTERVAL_MS);
+    loadCategories();
     loadSwipeItems();
 }
 
+function shareLocation() {
+    if (!navigator.geolocation) {
+        return;
+    }
+
+    navigator.geolocation.getCurrentPosition(async (position) => {
+        try {
+            await fetch(`${CONFIG.API_URL}/me/location`, {
+                method: 'PUT',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'Authorization': `Bearer ${state.token}`
+                },
+                body: JSON.stringify({
+                    latitude: position.coords.latitude,
+                    longitude: position.coords.longitude
+                })
+            });
+            document.getElementById('locationButton').textContent = 'Location set';
+            loadSwipeItems();
+        } catch (error) {
+            console.error('Error setting location:', error);
+        }
+    });
+}
+
+async function loadCategories() {
+    try {
+        const response = await fetch(`${CONFIG.API_URL}/categories`);
+        const tree = await response.json();
+
+        const select = document.getElementById('category');
+        select.length = 1;
+        const addOptions = (categories, depth) => {
+            categories.forEach(category => {
+                select.add(new Option('\u00a0\u00a0'.repeat(depth) + category.name, category.slug));
+                addOptions(category.children || [], depth + 1);
+            });
+        };
+        addOptions(tree, 0);
+    } catch (error) {
+        console.error('Error loading categories:', error);
+    }
+}
+
 // Navigation Functions
 function showTab(tabName) {
     document.querySelectorAll('.tab').forEach(t => {
@@ -169,12 +268,54 @@ function showItemsSubTab(subTab) {
 }
 
 // Items Management
-async function loadMyItems() {
-    try {
-        const response = await fetch(`${CONFIG.API_URL}/items`, {
+
+// Uploaded photos take precedence over a free-text image URL. size picks
+// the server-generated variant: 'thumbnail' for lists, 'card' for the deck.
+function itemImageUrl(item, size) {
+    const variantUrl = size === 'thumbnail' ? item.thumbnail_url : item.card_url;
+    if (variantUrl) {
+        return `${CONFIG.API_URL}${variantUrl}`;
+    }
+    return item.image_url;
+}
+
+async function uploadPhotos(itemId, files) {
+    const form = new FormData();
+    for (const file of files) {
+        form.append('photos', file);
+    }
+
+    const response = await fetch(`${CONFIG.API_URL}/items/${itemId}/photos`, {
+        method: 'POST',
+        headers: { 'Authorization': `Bearer ${state.token}` },
+        body: form
+    });
+    if (!response.ok) {
+        const data = await response.json();
+        throw new Error(data.error);
+    }
+}
+
+// List endpoints return { data, next_cursor }; this follows the cursors
+// until the last page.
+async function fetchAllPages(path) {
+    const results = [];
+    let cursor = '';
+    do {
+        const separator = path.includes('?') ? '&' : '?';
+        const response = await fetch(`${CONFIG.API_URL}${path}${separator}limit=100&cursor=${encodeURIComponent(cursor)}`, {
             headers: { 'Authorization': `Bearer ${state.token}` }
         });
-        const allItems = await response.json();
+        const page = await response.json();
+        results.push(...page.data);
+        cursor = page.next_cursor || '';
+    } while (cursor);
+    return results;
+}
+
+async function loadMyItems() {
+    try {
+        const allItems = await fetchAllPages('/items');
         const myItems = allItems.filter(item => item.user_id === state.currentUser.id);
         
         const list = document.getElementById('myItems');
@@ -185,8 +326,9 @@ async function loadMyItems() {
         }
 
         list.innerHTML = myItems.map(item => {
-            const imageHtml = item.image_url 
-                ? `<img src="${item.image_url}" alt="${item.title}" class="w-full h-40 object-cover rounded-t-xl">`
+            const imageUrl = itemImageUrl(item, 'thumbnail');
+            const imageHtml = imageUrl
+                ? `<img src="${imageUrl}" alt="${item.title}" class="w-full h-40 object-cover rounded-t-xl">`
                 : `<div class="w-full h-40 bg-gradient-to-br from-purple-100 to-pink-100 rounded-t-xl flex items-center justify-center">
                     <span class="text-5xl">Image of ${item.category}</span>
                   </div>`;
@@ -234,6 +376,12 @@ async function addItem(e) {
         });
 
         if (response.ok) {
+            const created = await response.json();
+            const files = document.getElementById('photos').files;
+            if (files.length > 0) {
+                await uploadPhotos(created.id, files);
+            }
+
             document.getElementById('successMessage').innerHTML = 
                 '<div class="bg-green-500 text-white p-3 rounded-lg mb-4 text-center">Item added successfully!</div>';
             e.target.reset();
@@ -264,20 +412,32 @@ async function deleteItem(id) {
 
 // Swipe Functionality
 async function loadSwipeItems() {
+    state.currentItems = [];
+    state.currentIndex = 0;
+    state.nextCursor = '';
+    await loadMoreSwipeItems();
+    displayCurrentItem();
+}
+
+async function loadMoreSwipeItems() {
     try {
-        const response = await fetch(`${CONFIG.API_URL}/items?exclude_own=true`, {
+        const response = await fetch(`${CONFIG.API_URL}/items?exclude_own=true&cursor=${encodeURIComponent(state.nextCursor)}`, {
             headers: { 'Authorization': `Bearer ${state.token}` }
         });
-        state.currentItems = await response.json();
-        state.currentIndex = 0;
-        displayCurrentItem();
+        const page = await response.json();
+        state.currentItems.push(...page.data);
+        state.nextCursor = page.next_cursor || '';
     } catch (error) {
         console.error('Error loading items:', error);
     }
 }
 
-function displayCurrentItem() {
+async function displayCurrentItem() {
     const content = document.getElementById('swipeContent');
+
+    if (state.currentIndex >= state.currentItems.length && state.nextCursor) {
+        await loadMoreSwipeItems();
+    }
     
     if (state.currentIndex >= state.currentItems.length) {
         content.innerHTML = `
@@ -290,8 +450,9 @@ function displayCurrentItem() {
     }
 
     const item = state.currentItems[state.currentIndex];
-    const imageHtml = item.image_url 
-        ? `<img src="${item.image_url}" alt="${item.title}" class="w-full h-64 object-cover rounded-xl mb-4">`
+    const imageUrl = itemImageUrl(item, 'card');
+    const imageHtml = imageUrl
+        ? `<img src="${imageUrl}" alt="${item.title}" class="w-full h-64 object-cover rounded-xl mb-4">`
         : `<div class="w-full h-64 bg-gradient-to-br from-purple-100 to-pink-100 rounded-xl mb-4 flex items-center justify-center">
             <span class="text-6xl">Image of a ${item.category}</span>
           </div>`;
@@ -299,7 +460,8 @@ function displayCurrentItem() {
     content.innerHTML = `
         <div class="mb-6">
             ${imageHtml}
-            <div class="text-sm text-gray-500 mb-2">Posted by ${item.owner_name}</div>
+            <div class="text-sm text-gray-500 mb-2">Posted by ${item.owner_name}${item.display_area ? ` in ${item.display_area}` : ''}${item.distance_km ? ` · ${item.distance_km} km away` : ''}</div>
+            ${item.suggested_for ? `<div class="inline-block bg-green-100 text-green-700 px-3 py-1 rounded-full text-xs font-semibold mb-2">Matches your want: ${item.suggested_for.keywords || item.suggested_for.category}</div>` : ''}
             <h2 class="text-3xl font-bold mb-3 text-gray-800">${item.title}</h2>
             ${item.category ? `<span class="inline-block bg-gray-100 px-3 py-1.5 rounded-full text-sm text-gray-600 mb-3">${item.category}</span>` : ''}
             <p class="text-gray-600 leading-relaxed">${item.description || 'No description provided'}</p>
@@ -343,10 +505,7 @@ async function swipe(direction) {
 // Matches & Comments
 async function loadMatches() {
     try {
-        const response = await fetch(`${CONFIG.API_URL}/matches`, {
-            headers: { 'Authorization': `Bearer ${state.token}` }
-        });
-        const matches = await response.json();
+        const matches = await fetchAllPages('/matches');
         
         const list = document.getElementById('matchesList');
         
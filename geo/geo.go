@@ -0,0 +1,83 @@
+// Package geo holds the distance math behind location-aware feeds.
+package geo
+
+import "math"
+
+// EarthRadiusKm is the mean radius of the Earth.
+const EarthRadiusKm = 6371.0
+
+// Point is a WGS84 coordinate in degrees.
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+// Valid reports whether p is a real coordinate.
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180 &&
+		!math.IsNaN(p.Lat) && !math.IsNaN(p.Lng)
+}
+
+// Distance returns the great-circle distance between a and b in kilometres,
+// using the haversine formula.
+func Distance(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat := lat2 - lat1
+	dLng := radians(b.Lng - a.Lng)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Box is a latitude/longitude rectangle.
+type Box struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+}
+
+// BoundingBox returns a rectangle containing every point within radiusKm of
+// center. It is meant as a cheap, indexable prefilter before Distance: near
+// the poles or the antimeridian it gives up on longitude and spans all of it.
+func BoundingBox(center Point, radiusKm float64) Box {
+	dLat := degrees(radiusKm / EarthRadiusKm)
+	box := Box{
+		MinLat: center.Lat - dLat,
+		MaxLat: center.Lat + dLat,
+		MinLng: -180,
+		MaxLng: 180,
+	}
+
+	if box.MinLat <= -90 || box.MaxLat >= 90 {
+		box.MinLat = math.Max(box.MinLat, -90)
+		box.MaxLat = math.Min(box.MaxLat, 90)
+		return box
+	}
+
+	dLng := degrees(math.Asin(math.Sin(radiusKm/EarthRadiusKm) / math.Cos(radians(center.Lat))))
+	if center.Lng-dLng >= -180 && center.Lng+dLng <= 180 {
+		box.MinLng = center.Lng - dLng
+		box.MaxLng = center.Lng + dLng
+	}
+	return box
+}
+
+// MaxRoundingKm is the most RoundDistance can round a distance down by.
+const MaxRoundingKm = 2.5
+
+// RoundDistance coarsens a distance before it is shown to other users, so
+// repeated lookups from different places cannot pin down an exact position:
+// whole kilometres up to 10 km (never below 1), then steps of 5 km.
+func RoundDistance(km float64) float64 {
+	switch {
+	case km < 1:
+		return 1
+	case km < 10:
+		return math.Round(km)
+	default:
+		return math.Round(km/5) * 5
+	}
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
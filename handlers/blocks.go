@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/notLeoHirano/bartr/middleware"
+	"github.com/notLeoHirano/bartr/models"
+)
+
+func (h *Handler) GetBlocks(c *gin.Context) {
+	blocks, err := h.service.GetBlocks(middleware.GetUserID(c))
+	if err != nil {
+		log.Printf("Error fetching blocks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, blocks)
+}
+
+func (h *Handler) BlockUser(c *gin.Context) {
+	var req models.BlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.service.BlockUser(middleware.GetUserID(c), req.UserID); err != nil {
+		switch err.Error() {
+		case "you cannot block yourself":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error blocking user: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "User blocked"})
+}
+
+func (h *Handler) UnblockUser(c *gin.Context) {
+	blockedID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.service.UnblockUser(middleware.GetUserID(c), blockedID); err != nil {
+		if err.Error() == "block not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error unblocking user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked"})
+}
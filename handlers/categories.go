@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetCategories(c *gin.Context) {
+	categories, err := h.service.GetCategoryTree()
+	if err != nil {
+		log.Printf("Error fetching categories: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
@@ -24,7 +24,7 @@ func (h *Handler) CreateComment(c *gin.Context) {
 	}
 
 	if err := h.service.CreateComment(comment); err != nil {
-		if err.Error() == "you are not part of this match" {
+		if err.Error() == "you are not part of this match" || err.Error() == "verify your email address first" {
 			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 			return
 		}
@@ -43,8 +43,18 @@ func (h *Handler) GetComments(c *gin.Context) {
 		return
 	}
 
-	comments, err := h.service.GetComments(matchID)
+	page, err := pageRequest(c)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comments, err := h.service.GetComments(matchID, page)
+	if err != nil {
+		if err.Error() == "invalid cursor" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		log.Printf("Error fetching comments: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
 		return
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/notLeoHirano/bartr/middleware"
+	"github.com/notLeoHirano/bartr/models"
+)
+
+func (h *Handler) GetGroupMatches(c *gin.Context) {
+	groups, err := h.service.GetGroupMatches(middleware.GetUserID(c))
+	if err != nil {
+		log.Printf("Error fetching group matches: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch group matches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+func (h *Handler) AcceptGroupMatch(c *gin.Context) {
+	h.respondToGroupMatch(c, h.service.AcceptGroupMatch)
+}
+
+func (h *Handler) DeclineGroupMatch(c *gin.Context) {
+	h.respondToGroupMatch(c, h.service.DeclineGroupMatch)
+}
+
+func (h *Handler) CancelGroupMatch(c *gin.Context) {
+	h.respondToGroupMatch(c, h.service.CancelGroupMatch)
+}
+
+func (h *Handler) CompleteGroupMatch(c *gin.Context) {
+	h.respondToGroupMatch(c, h.service.CompleteGroupMatch)
+}
+
+func (h *Handler) respondToGroupMatch(c *gin.Context, respond func(groupID, userID int) (*models.GroupMatch, error)) {
+	groupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group match ID"})
+		return
+	}
+
+	group, err := respond(groupID, middleware.GetUserID(c))
+	if err != nil {
+		switch {
+		case err.Error() == "you are not part of this trade":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "trade is not"),
+			err.Error() == "you already responded to this trade",
+			err.Error() == "you already confirmed this trade",
+			err.Error() == "items in this trade are no longer available":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error updating group match: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group match"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
@@ -1,6 +1,13 @@
 package handlers
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/notLeoHirano/bartr/models"
 	"github.com/notLeoHirano/bartr/service"
 )
 
@@ -10,4 +17,90 @@ type Handler struct {
 
 func New(service *service.Service) *Handler {
 	return &Handler{service: service}
-}
\ No newline at end of file
+}
+
+// pageRequest reads the cursor and limit query parameters of list endpoints.
+func pageRequest(c *gin.Context) (models.PageRequest, error) {
+	page := models.PageRequest{Cursor: c.Query("cursor")}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return page, fmt.Errorf("limit must be a positive integer")
+		}
+		page.Limit = limit
+	}
+
+	return page, nil
+}
+
+// itemFilter reads the feed filter query parameters. List parameters
+// (category, condition, exclude_owners) may be repeated or comma-separated.
+func itemFilter(c *gin.Context) (models.ItemFilter, error) {
+	filter := models.ItemFilter{
+		ExcludeOwn: c.Query("exclude_own") == "true",
+		Categories: queryList(c, "category"),
+		Conditions: queryList(c, "condition"),
+	}
+
+	var err error
+	if filter.MinValue, err = queryInt(c, "min_value"); err != nil {
+		return filter, err
+	}
+	if filter.MaxValue, err = queryInt(c, "max_value"); err != nil {
+		return filter, err
+	}
+
+	if raw := c.Query("radius_km"); raw != "" {
+		radius, err := strconv.ParseFloat(raw, 64)
+		if err != nil || !(radius > 0) {
+			return filter, fmt.Errorf("radius_km must be a positive number")
+		}
+		filter.RadiusKm = &radius
+	}
+
+	if raw := c.Query("posted_since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			if since, err = time.Parse("2006-01-02", raw); err != nil {
+				return filter, fmt.Errorf("posted_since must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+			}
+		}
+		filter.PostedSince = &since
+	}
+
+	for _, raw := range queryList(c, "exclude_owners") {
+		id, err := strconv.Atoi(raw)
+		if err != nil || id < 1 {
+			return filter, fmt.Errorf("exclude_owners must be a list of user ids")
+		}
+		filter.ExcludeOwners = append(filter.ExcludeOwners, id)
+	}
+
+	return filter, nil
+}
+
+// queryList collects every value of a repeated and/or comma-separated query parameter.
+func queryList(c *gin.Context, key string) []string {
+	var list []string
+	for _, raw := range c.QueryArray(key) {
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+	}
+	return list
+}
+
+func queryInt(c *gin.Context, key string) (*int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return &n, nil
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -14,10 +15,25 @@ import (
 
 func (h *Handler) GetItems(c *gin.Context) {
 	userID := middleware.GetUserID(c)
-	excludeOwn := c.Query("exclude_own") == "true"
 
-	items, err := h.service.GetItems(userID, excludeOwn)
+	filter, err := itemFilter(c)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := pageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := h.service.GetItems(userID, filter, page)
+	if err != nil {
+		if isFilterError(err) || err.Error() == "invalid cursor" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		log.Printf("Error fetching items: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch items"})
 		return
@@ -26,6 +42,60 @@ func (h *Handler) GetItems(c *gin.Context) {
 	c.JSON(http.StatusOK, items)
 }
 
+func (h *Handler) GetDeck(c *gin.Context) {
+	filter, err := itemFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := pageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deck, err := h.service.GetDeck(middleware.GetUserID(c), filter, page.Limit)
+	if err != nil {
+		if isFilterError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error fetching deck: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deck"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deck)
+}
+
+func (h *Handler) GetSimilarItems(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	page, err := pageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := h.service.GetSimilarItems(id, middleware.GetUserID(c), page.Limit)
+	if err != nil {
+		if err.Error() == "item not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error fetching similar items: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch similar items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
 func (h *Handler) CreateItem(c *gin.Context) {
 	var item models.Item
 	if err := c.ShouldBindJSON(&item); err != nil {
@@ -46,10 +116,14 @@ func (h *Handler) CreateItem(c *gin.Context) {
 	item.UserID = middleware.GetUserID(c)
 
 	if err := h.service.CreateItem(&item); err != nil {
-		if err.Error() == "title is required" {
+		if err.Error() == "title is required" || isItemFieldError(err) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if err.Error() == "verify your email address first" {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		log.Printf("Error creating item: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create item"})
 		return
@@ -75,4 +149,186 @@ func (h *Handler) DeleteItem(c *gin.Context) {
 
 	log.Printf("Deleted item: %d", id)
 	c.JSON(http.StatusOK, gin.H{"message": "Item deleted"})
-}
\ No newline at end of file
+}
+
+// ReplaceItem handles PUT /items/:id. Every editable field is overwritten, so
+// fields omitted from the body are cleared.
+func (h *Handler) ReplaceItem(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var item models.Item
+	if err := c.ShouldBindJSON(&item); err != nil {
+		var verr validator.ValidationErrors
+		if errors.As(err, &verr) {
+			for _, fe := range verr {
+				if fe.Field() == "Title" {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+					return
+				}
+			}
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	h.updateItem(c, id, models.UpdateItemRequest{
+		Title:          &item.Title,
+		Description:    &item.Description,
+		Category:       &item.Category,
+		ImageURL:       &item.ImageURL,
+		Condition:      &item.Condition,
+		EstimatedValue: models.NullableInt{Set: true, Value: item.EstimatedValue},
+		DisplayArea:    &item.DisplayArea,
+		Location:       models.NullableLocation{Set: true, Value: item.Location},
+	})
+}
+
+// UpdateItem handles PATCH /items/:id. Only fields present in the body change.
+func (h *Handler) UpdateItem(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var update models.UpdateItemRequest
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	h.updateItem(c, id, update)
+}
+
+func (h *Handler) updateItem(c *gin.Context, id int, update models.UpdateItemRequest) {
+	userID := middleware.GetUserID(c)
+
+	item, err := h.service.UpdateItem(id, userID, update)
+	if err != nil {
+		switch {
+		case err.Error() == "title is required", err.Error() == "no fields to update", isItemFieldError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err.Error() == "item not found or you don't have permission to edit it":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error updating item: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update item"})
+		}
+		return
+	}
+
+	log.Printf("Updated item: %d - %s", item.ID, item.Title)
+	c.JSON(http.StatusOK, item)
+}
+
+func (h *Handler) GetItemRevisions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	history, err := h.service.GetItemHistory(id, middleware.GetUserID(c))
+	if err != nil {
+		if err.Error() == "item not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error fetching item revisions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+func (h *Handler) SetItemStatus(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var req models.ItemStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	item, err := h.service.SetItemStatus(id, middleware.GetUserID(c), req.Status)
+	if err != nil {
+		switch {
+		case err.Error() == "status must be 'available' or 'withdrawn'":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err.Error() == "item not found or you don't have permission to edit it":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case err.Error() == "item status changed concurrently, please retry",
+			strings.HasPrefix(err.Error(), "cannot change item status"):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error updating item status: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update item status"})
+		}
+		return
+	}
+
+	log.Printf("Item %d is now %s", item.ID, item.Status)
+	c.JSON(http.StatusOK, item)
+}
+
+func (h *Handler) SearchItems(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	filter, err := itemFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = n
+	}
+
+	results, err := h.service.SearchItems(userID, filter, c.Query("q"), limit)
+	if err != nil {
+		if err.Error() == "search query is required" || isFilterError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error searching items: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// isItemFieldError reports whether err rejects an item's category, condition,
+// value or location.
+func isItemFieldError(err error) bool {
+	switch err.Error() {
+	case "invalid condition", "estimated_value cannot be negative", "unknown category", "invalid coordinates":
+		return true
+	}
+	return strings.HasPrefix(err.Error(), "display_area must be")
+}
+
+// isFilterError reports whether err is a validation error from the feed filters.
+func isFilterError(err error) bool {
+	switch err.Error() {
+	case "invalid condition", "min_value cannot be greater than max_value", "unknown category",
+		"set your location to filter by distance":
+		return true
+	}
+	return strings.HasPrefix(err.Error(), "radius_km must be")
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/notLeoHirano/bartr/middleware"
+)
+
+// GetJWKS handles GET /.well-known/jwks.json, publishing the public keys
+// tokens are signed with.
+func (h *Handler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, middleware.CurrentJWKS())
+}
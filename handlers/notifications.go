@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/notLeoHirano/bartr/middleware"
+)
+
+func (h *Handler) GetNotifications(c *gin.Context) {
+	page, err := pageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notifications, err := h.service.GetNotifications(middleware.GetUserID(c), page)
+	if err != nil {
+		if err.Error() == "invalid cursor" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error fetching notifications: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
+
+func (h *Handler) MarkNotificationsRead(c *gin.Context) {
+	if err := h.service.MarkNotificationsRead(middleware.GetUserID(c)); err != nil {
+		log.Printf("Error marking notifications read: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications marked read"})
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/notLeoHirano/bartr/media"
+	"github.com/notLeoHirano/bartr/middleware"
+	"github.com/notLeoHirano/bartr/models"
+	"github.com/notLeoHirano/bartr/service"
+)
+
+// UploadItemPhotos accepts one or more images in the multipart field "photos".
+func (h *Handler) UploadItemPhotos(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	// Leave a little room on top of the images for multipart framing
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxPhotosPerItem*media.MaxUploadSize+1<<20)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart form"})
+		return
+	}
+	defer form.RemoveAll()
+
+	headers := form.File["photos"]
+	files := make([]io.Reader, 0, len(headers))
+	for _, fh := range headers {
+		if fh.Size > media.MaxUploadSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": media.ErrTooLarge.Error()})
+			return
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart form"})
+			return
+		}
+		defer f.Close()
+		files = append(files, f)
+	}
+
+	photos, err := h.service.AddItemPhotos(id, middleware.GetUserID(c), files)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		case errors.Is(err, media.ErrUnsupportedType), errors.Is(err, media.ErrInvalidImage):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		case err.Error() == "at least one photo is required",
+			strings.HasPrefix(err.Error(), "an item can have at most"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err.Error() == "item not found or you don't have permission to edit it":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error uploading photos: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload photos"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, photos)
+}
+
+func (h *Handler) GetItemPhotos(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	photos, err := h.service.GetItemPhotos(id)
+	if err != nil {
+		if err.Error() == "item not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error fetching photos: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, photos)
+}
+
+func (h *Handler) DeleteItemPhoto(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+	photoID, err := strconv.Atoi(c.Param("photo_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
+		return
+	}
+
+	if err := h.service.DeleteItemPhoto(id, photoID, middleware.GetUserID(c)); err != nil {
+		switch err.Error() {
+		case "item not found or you don't have permission to edit it":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case "photo not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error deleting photo: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete photo"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Photo deleted"})
+}
+
+func (h *Handler) ReorderItemPhotos(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var req models.PhotoOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	photos, err := h.service.ReorderItemPhotos(id, middleware.GetUserID(c), req.PhotoIDs)
+	if err != nil {
+		switch err.Error() {
+		case "item not found or you don't have permission to edit it":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case "photo_ids must list every photo of the item exactly once":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error reordering photos: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder photos"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, photos)
+}
+
+// ServePhoto streams a stored photo, or one of its resized variants when
+// ?size=thumb or ?size=card is given. It is public so that <img> tags can load
+// it without an Authorization header.
+func (h *Handler) ServePhoto(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
+		return
+	}
+
+	contentType, size, rc, err := h.service.OpenPhoto(id, c.Query("size"))
+	if err != nil {
+		switch err.Error() {
+		case "photo not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case "size must be one of original, card or thumb":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error opening photo: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load photo"})
+		return
+	}
+	defer rc.Close()
+
+	// Stored photos never change, a new upload always gets a new ID
+	c.DataFromReader(http.StatusOK, int64(size), contentType, rc, map[string]string{
+		"Cache-Control":          "public, max-age=31536000, immutable",
+		"X-Content-Type-Options": "nosniff",
+	})
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/notLeoHirano/bartr/middleware"
+)
+
+// GetSessions handles GET /sessions.
+func (h *Handler) GetSessions(c *gin.Context) {
+	sessions, err := h.service.GetSessions(middleware.GetUserID(c), middleware.GetSessionID(c))
+	if err != nil {
+		log.Printf("Error fetching sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession handles DELETE /sessions/:session_id.
+func (h *Handler) RevokeSession(c *gin.Context) {
+	err := h.service.RevokeSession(middleware.GetUserID(c), c.Param("session_id"))
+	if err != nil {
+		if err.Error() == "session not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error revoking session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions handles DELETE /sessions, signing out every session
+// but the one making the request.
+func (h *Handler) RevokeOtherSessions(c *gin.Context) {
+	n, err := h.service.RevokeOtherSessions(middleware.GetUserID(c), middleware.GetSessionID(c))
+	if err != nil {
+		log.Printf("Error revoking sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked", "revoked": n})
+}
@@ -3,12 +3,17 @@ package handlers
 import (
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/notLeoHirano/bartr/middleware"
 	"github.com/notLeoHirano/bartr/models"
 )
 
+// maxIdempotencyKeyLength bounds the Idempotency-Key header.
+const maxIdempotencyKeyLength = 255
+
 func (h *Handler) CreateSwipe(c *gin.Context) {
 	print()
 	var swipe models.Swipe
@@ -18,27 +23,108 @@ func (h *Handler) CreateSwipe(c *gin.Context) {
 	}
 
 	swipe.UserID = middleware.GetUserID(c)
+	swipe.IdempotencyKey = c.GetHeader("Idempotency-Key")
+	if len(swipe.IdempotencyKey) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+		return
+	}
 
-	if err := h.service.CreateSwipe(&swipe); err != nil {
-		if err.Error() == "direction must be 'left' or 'right'" {
+	created, err := h.service.CreateSwipe(&swipe)
+	if err != nil {
+		switch err.Error() {
+		case "direction must be 'left', 'right' or 'super'", "you cannot super like your own item":
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
+		case "item not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case "item is not available":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case "you already swiped left on this item", "you already swiped right on this item",
+			"you already swiped super on this item":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "swipe": swipe})
+			return
+		case "idempotency key was already used for a different swipe":
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case "verify your email address first":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "you have used all") {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
 		}
 		log.Printf("Error creating swipe: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create swipe"})
 		return
 	}
 
+	if !created {
+		c.JSON(http.StatusOK, swipe)
+		return
+	}
 	c.JSON(http.StatusCreated, swipe)
 }
 
 
 
+func (h *Handler) CreateSwipeBatch(c *gin.Context) {
+	var req models.BatchSwipeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	results, err := h.service.CreateSwipeBatch(middleware.GetUserID(c), req.Swipes)
+	if err != nil {
+		if err.Error() == "a batch needs at least one swipe" || strings.HasPrefix(err.Error(), "a batch can have at most") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error creating swipe batch: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create swipes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+func (h *Handler) UndoSwipe(c *gin.Context) {
+	swipe, err := h.service.UndoSwipe(middleware.GetUserID(c))
+	if err != nil {
+		switch err.Error() {
+		case "no swipe to undo":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case "swipe is too old to undo", "swipe already led to a trade in progress":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error undoing swipe: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo swipe"})
+		return
+	}
+
+	c.JSON(http.StatusOK, swipe)
+}
+
 func (h *Handler) GetMatches(c *gin.Context) {
 	userID := middleware.GetUserID(c)
 
-	matches, err := h.service.GetMatches(userID)
+	page, err := pageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	matches, err := h.service.GetMatches(userID, page)
 	if err != nil {
+		if err.Error() == "invalid cursor" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		log.Printf("Error fetching matches: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch matches"})
 		return
@@ -46,3 +132,65 @@ func (h *Handler) GetMatches(c *gin.Context) {
 
 	c.JSON(http.StatusOK, matches)
 }
+
+func (h *Handler) AcceptMatch(c *gin.Context) {
+	h.transitionMatch(c, h.service.AcceptMatch)
+}
+
+func (h *Handler) DeclineMatch(c *gin.Context) {
+	h.transitionMatch(c, h.service.DeclineMatch)
+}
+
+func (h *Handler) CompleteMatch(c *gin.Context) {
+	h.transitionMatch(c, h.service.CompleteMatch)
+}
+
+func (h *Handler) CancelMatch(c *gin.Context) {
+	h.transitionMatch(c, h.service.CancelMatch)
+}
+
+func (h *Handler) Unmatch(c *gin.Context) {
+	matchID, err := strconv.Atoi(c.Param("match_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid match ID"})
+		return
+	}
+
+	if err := h.service.Unmatch(matchID, middleware.GetUserID(c)); err != nil {
+		if err.Error() == "you are not part of this match" {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error archiving match: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unmatch"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unmatched"})
+}
+
+func (h *Handler) transitionMatch(c *gin.Context, transition func(matchID, userID int) (*models.Match, error)) {
+	matchID, err := strconv.Atoi(c.Param("match_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid match ID"})
+		return
+	}
+
+	match, err := transition(matchID, middleware.GetUserID(c))
+	if err != nil {
+		switch {
+		case err.Error() == "you are not part of this match":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case err.Error() == "items in this match are no longer available",
+			err.Error() == "you already confirmed this trade",
+			strings.HasPrefix(err.Error(), "match is not "):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error updating match: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update match"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, match)
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/notLeoHirano/bartr/middleware"
@@ -27,17 +28,7 @@ func (h *Handler) Register(c *gin.Context) {
 		return
 	}
 
-	token, err := middleware.GenerateToken(user.ID, user.Email)
-	if err != nil {
-		log.Printf("Error generating token: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
-	c.JSON(http.StatusCreated, models.AuthResponse{
-		Token: token,
-		User:  *user,
-	})
+	h.respondWithTokens(c, http.StatusCreated, user, nil)
 }
 
 func (h *Handler) Login(c *gin.Context) {
@@ -53,16 +44,156 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := middleware.GenerateToken(user.ID, user.Email)
+	h.respondWithTokens(c, http.StatusOK, user, nil)
+}
+
+// ForgotPassword handles POST /auth/forgot-password. The answer is the same
+// whether or not the email has an account.
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid email is required"})
+		return
+	}
+
+	if err := h.service.ForgotPassword(req.Email); err != nil {
+		log.Printf("Error sending password reset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send password reset email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If that email has an account, a reset token has been sent to it"})
+}
+
+// ResetPassword handles POST /auth/reset-password.
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token and a password of at least 6 characters are required"})
+		return
+	}
+
+	if err := h.service.ResetPassword(req); err != nil {
+		if err.Error() == "invalid or expired reset token" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error resetting password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated. Please sign in again."})
+}
+
+// VerifyEmail handles POST /auth/verify-email with a token from the
+// verification email.
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	user, err := h.service.VerifyEmail(req.Token)
+	if err != nil {
+		if err.Error() == "invalid or expired verification token" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error verifying email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ResendVerificationEmail handles POST /me/verification-email.
+func (h *Handler) ResendVerificationEmail(c *gin.Context) {
+	if err := h.service.SendVerificationEmail(middleware.GetUserID(c)); err != nil {
+		if err.Error() == "email is already verified" {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error sending verification email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
+
+// Refresh handles POST /auth/refresh. The refresh token is exchanged for a
+// new one along with a new access token.
+func (h *Handler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	user, session, err := h.service.RefreshSession(req.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		switch err.Error() {
+		case "invalid refresh token", "refresh token has expired", "refresh token was already used":
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error refreshing token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	h.respondWithTokens(c, http.StatusOK, user, session)
+}
+
+// Logout handles POST /auth/logout. The refresh token's session ends, along
+// with its access tokens.
+func (h *Handler) Logout(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	if err := h.service.Logout(req.RefreshToken); err != nil {
+		if err.Error() == "invalid refresh token" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error logging out: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// respondWithTokens sends an access token for user with a refresh token. A
+// nil session starts a new one for the requesting device.
+func (h *Handler) respondWithTokens(c *gin.Context, status int, user *models.User, session *models.SessionTokens) {
+	if session == nil {
+		var err error
+		if session, err = h.service.StartSession(user.ID, c.Request.UserAgent(), c.ClientIP()); err != nil {
+			log.Printf("Error starting session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+	}
+
+	token, err := middleware.GenerateToken(user.ID, user.Email, session.SessionID)
 	if err != nil {
 		log.Printf("Error generating token: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.AuthResponse{
-		Token: token,
-		User:  *user,
+	c.JSON(status, models.AuthResponse{
+		Token:        token,
+		ExpiresIn:    int(middleware.AccessTokenTTL.Seconds()),
+		RefreshToken: session.RefreshToken,
+		User:         *user,
 	})
 }
 
@@ -76,3 +207,54 @@ func (h *Handler) GetMe(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user)
 }
+
+// SetLocation handles PUT /me/location.
+func (h *Handler) SetLocation(c *gin.Context) {
+	var req models.LocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "latitude and longitude are required"})
+		return
+	}
+
+	user, err := h.service.SetLocation(middleware.GetUserID(c), req)
+	if err != nil {
+		if err.Error() == "invalid coordinates" || strings.HasPrefix(err.Error(), "display_area must be") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error setting location: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set location"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// SetInterestHints handles PUT /me/interest-hints.
+func (h *Handler) SetInterestHints(c *gin.Context) {
+	var req models.InterestHintsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "enabled is required"})
+		return
+	}
+
+	user, err := h.service.SetInterestHints(middleware.GetUserID(c), *req.Enabled)
+	if err != nil {
+		log.Printf("Error setting interest hints: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set interest hints"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ClearLocation handles DELETE /me/location.
+func (h *Handler) ClearLocation(c *gin.Context) {
+	if err := h.service.ClearLocation(middleware.GetUserID(c)); err != nil {
+		log.Printf("Error clearing location: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear location"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Location cleared"})
+}
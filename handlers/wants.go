@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/notLeoHirano/bartr/middleware"
+	"github.com/notLeoHirano/bartr/models"
+)
+
+func (h *Handler) GetWants(c *gin.Context) {
+	wants, err := h.service.GetWants(middleware.GetUserID(c))
+	if err != nil {
+		log.Printf("Error fetching wants: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wants"})
+		return
+	}
+
+	c.JSON(http.StatusOK, wants)
+}
+
+func (h *Handler) CreateWant(c *gin.Context) {
+	var req models.WantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	want, err := h.service.CreateWant(middleware.GetUserID(c), req)
+	if err != nil {
+		switch {
+		case err.Error() == "a want needs keywords or a category",
+			err.Error() == "keywords must contain letters or digits",
+			err.Error() == "unknown category",
+			strings.HasPrefix(err.Error(), "max_distance_km must be"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "you can have at most"):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error creating want: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create want"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, want)
+}
+
+func (h *Handler) DeleteWant(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid want ID"})
+		return
+	}
+
+	if err := h.service.DeleteWant(id, middleware.GetUserID(c)); err != nil {
+		if err.Error() == "want not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error deleting want: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete want"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Want deleted"})
+}
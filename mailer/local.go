@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LocalMailer is a Mailer for development and tests. It logs every message
+// and, given a directory, also writes each one there as a file instead of
+// delivering it.
+type LocalMailer struct {
+	dir string
+
+	mu sync.Mutex
+	n  int
+}
+
+// NewLocal returns a mailer writing messages to dir. An empty dir only logs
+// them.
+func NewLocal(dir string) (*LocalMailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &LocalMailer{dir: dir}, nil
+}
+
+func (m *LocalMailer) Send(msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	log.Printf("Mail to %s: %s", msg.To, msg.Subject)
+	if m.dir == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	m.n++
+	name := fmt.Sprintf("%s-%03d.txt", time.Now().UTC().Format("20060102T150405"), m.n)
+	m.mu.Unlock()
+
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	return os.WriteFile(filepath.Join(m.dir, name), []byte(content), 0o600)
+}
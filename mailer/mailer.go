@@ -0,0 +1,30 @@
+package mailer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email, such as password reset links.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// validate rejects line breaks in header fields, which would let the
+// recipient or subject inject extra headers.
+func (m Message) validate() error {
+	if m.To == "" {
+		return fmt.Errorf("message has no recipient")
+	}
+	if strings.ContainsAny(m.To+m.Subject, "\r\n") {
+		return fmt.Errorf("message headers cannot contain line breaks")
+	}
+	return nil
+}
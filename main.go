@@ -1,18 +1,75 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"os"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/notLeoHirano/bartr/database"
 	"github.com/notLeoHirano/bartr/handlers"
+	"github.com/notLeoHirano/bartr/mailer"
 	"github.com/notLeoHirano/bartr/middleware"
 	"github.com/notLeoHirano/bartr/service"
+	"github.com/notLeoHirano/bartr/storage"
 	"github.com/notLeoHirano/bartr/store"
 )
 
+// tradeCycleInterval is how often the swipe graph is searched for ring trades.
+const tradeCycleInterval = 5 * time.Minute
+
+// similarityInterval is how often the item similarity model is rebuilt.
+const similarityInterval = 15 * time.Minute
+
+// sessionCacheTTL is how long a session found active is trusted before it
+// is checked again, and so how stale its last-seen time can be.
+const sessionCacheTTL = time.Minute
+
+// keyRingFromEnv picks the JWT signing keys: a key ring file named by
+// BARTR_JWT_KEYS, else a single HS256 secret in BARTR_JWT_SECRET, else the
+// development key.
+func keyRingFromEnv() (*middleware.KeyRing, error) {
+	if path := os.Getenv("BARTR_JWT_KEYS"); path != "" {
+		return middleware.LoadKeyRing(path)
+	}
+	if secret := os.Getenv("BARTR_JWT_SECRET"); secret != "" {
+		key, err := middleware.NewHMACKey("default", []byte(secret))
+		if err != nil {
+			return nil, fmt.Errorf("BARTR_JWT_SECRET: %w", err)
+		}
+		return middleware.NewKeyRing("default", key)
+	}
+
+	log.Println("WARNING: no JWT keys configured, signing tokens with the development key")
+	return middleware.DevKeyRing(), nil
+}
+
+// mailerFromEnv sends email through the SMTP server in BARTR_SMTP_ADDR
+// (host:port) when it is set, and otherwise writes it to ./mail.
+func mailerFromEnv() (mailer.Mailer, error) {
+	if addr := os.Getenv("BARTR_SMTP_ADDR"); addr != "" {
+		return mailer.NewSMTP(addr, os.Getenv("BARTR_SMTP_USERNAME"), os.Getenv("BARTR_SMTP_PASSWORD"),
+			os.Getenv("BARTR_MAIL_FROM"))
+	}
+
+	log.Println("WARNING: no SMTP server configured, writing email to ./mail")
+	return mailer.NewLocal("./mail")
+}
+
 func main() {
+	keys, err := keyRingFromEnv()
+	if err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+	middleware.UseKeyRing(keys)
+
+	mail, err := mailerFromEnv()
+	if err != nil {
+		log.Fatal("Failed to set up email:", err)
+	}
+
 	// Initialize database
 	db, err := database.New("./bartr.db")
 	if err != nil {
@@ -24,19 +81,58 @@ func main() {
 		log.Fatal("Failed to initialize database:", err)
 	}
 
+	// Uploaded photos live on the local filesystem
+	blobs, err := storage.NewLocal("./uploads")
+	if err != nil {
+		log.Fatal("Failed to open photo storage:", err)
+	}
+
 	// Initialize layers
 	st := store.New(db.DB)
-	svc := service.New(st)
+	sessions := middleware.NewSessionCache(st, sessionCacheTTL)
+	svc := service.New(st, service.WithBlobStore(blobs), service.WithSessionRevoked(sessions.Forget),
+		service.WithMailer(mail))
 	handler := handlers.New(svc)
 
+	// Generate thumbnails for photos uploaded before variants existed
+	go func() {
+		n, err := svc.BackfillPhotoVariants()
+		if err != nil {
+			log.Printf("Error backfilling photo variants: %v", err)
+		} else if n > 0 {
+			log.Printf("Generated variants for %d photo(s)", n)
+		}
+	}()
+
+	// Look for ring trades in the background
+	go func() {
+		for ; ; time.Sleep(tradeCycleInterval) {
+			n, err := svc.FindTradeCycles()
+			if err != nil {
+				log.Printf("Error finding trade cycles: %v", err)
+			} else if n > 0 {
+				log.Printf("Proposed %d ring trade(s)", n)
+			}
+		}
+	}()
+
+	// Rebuild "people who liked this also liked" in the background
+	go func() {
+		for ; ; time.Sleep(similarityInterval) {
+			if _, err := svc.BuildItemSimilarities(); err != nil {
+				log.Printf("Error building item similarities: %v", err)
+			}
+		}
+	}()
+
 	// Setup router
 	r := gin.Default()
 	
 	// CORS must be first
 	r.Use(cors.New(cors.Config{
 		AllowAllOrigins:  true,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Idempotency-Key"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: false,
 		MaxAge:           12 * 3600,
@@ -47,25 +143,85 @@ func main() {
 	{
 		auth.POST("/register", handler.Register)
 		auth.POST("/login", handler.Login)
+		auth.POST("/refresh", handler.Refresh)
+		auth.POST("/logout", handler.Logout)
+		auth.POST("/forgot-password", handler.ForgotPassword)
+		auth.POST("/reset-password", handler.ResetPassword)
+		auth.POST("/verify-email", handler.VerifyEmail)
 	}
 
+	// Photos are public so they can be used directly in <img> tags
+	r.GET("/photos/:id", handler.ServePhoto)
+	r.GET("/categories", handler.GetCategories)
+
+	// Public keys for services verifying our tokens
+	r.GET("/.well-known/jwks.json", handler.GetJWKS)
+
 	// Protected routes
 	api := r.Group("/")
-	api.Use(middleware.AuthRequired())
+	api.Use(middleware.AuthRequired(sessions))
 	{
 		// User
 		api.GET("/me", handler.GetMe)
+		api.PUT("/me/location", handler.SetLocation)
+		api.DELETE("/me/location", handler.ClearLocation)
+		api.PUT("/me/interest-hints", handler.SetInterestHints)
+		api.POST("/me/verification-email", handler.ResendVerificationEmail)
+
+		// Sessions
+		api.GET("/sessions", handler.GetSessions)
+		api.DELETE("/sessions", handler.RevokeOtherSessions)
+		api.DELETE("/sessions/:session_id", handler.RevokeSession)
 
 		// Items
+		api.GET("/deck", handler.GetDeck)
 		api.GET("/items", handler.GetItems)
+		api.GET("/items/search", handler.SearchItems)
 		api.POST("/items", handler.CreateItem)
+		api.PUT("/items/:id", handler.ReplaceItem)
+		api.PATCH("/items/:id", handler.UpdateItem)
 		api.DELETE("/items/:id", handler.DeleteItem)
+		api.GET("/items/:id/revisions", handler.GetItemRevisions)
+		api.GET("/items/:id/similar", handler.GetSimilarItems)
+		api.PUT("/items/:id/status", handler.SetItemStatus)
+		api.GET("/items/:id/photos", handler.GetItemPhotos)
+		api.POST("/items/:id/photos", handler.UploadItemPhotos)
+		api.PUT("/items/:id/photos/order", handler.ReorderItemPhotos)
+		api.DELETE("/items/:id/photos/:photo_id", handler.DeleteItemPhoto)
+
+		// Wants
+		api.GET("/wants", handler.GetWants)
+		api.POST("/wants", handler.CreateWant)
+		api.DELETE("/wants/:id", handler.DeleteWant)
 
 		// Swipes
 		api.POST("/swipes", handler.CreateSwipe)
+		api.POST("/swipes/batch", handler.CreateSwipeBatch)
+		api.POST("/swipes/undo", handler.UndoSwipe)
 
 		// Matches
 		api.GET("/matches", handler.GetMatches)
+		api.POST("/matches/:match_id/accept", handler.AcceptMatch)
+		api.POST("/matches/:match_id/decline", handler.DeclineMatch)
+		api.POST("/matches/:match_id/complete", handler.CompleteMatch)
+		api.POST("/matches/:match_id/cancel", handler.CancelMatch)
+		api.POST("/matches/:match_id/unmatch", handler.Unmatch)
+
+		// Blocks
+		api.GET("/blocks", handler.GetBlocks)
+		api.POST("/blocks", handler.BlockUser)
+		api.DELETE("/blocks/:user_id", handler.UnblockUser)
+
+		// Ring trades
+		api.GET("/group-matches", handler.GetGroupMatches)
+		api.POST("/group-matches/:id/accept", handler.AcceptGroupMatch)
+		api.POST("/group-matches/:id/decline", handler.DeclineGroupMatch)
+		api.POST("/group-matches/:id/cancel", handler.CancelGroupMatch)
+		api.POST("/group-matches/:id/complete", handler.CompleteGroupMatch)
+
+		// Notifications
+		api.GET("/notifications", handler.GetNotifications)
+		api.POST("/notifications/read", handler.MarkNotificationsRead)
 
 		// Comments
 		api.POST("/comments", handler.CreateComment)
@@ -2,22 +2,41 @@ package main
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/notLeoHirano/bartr/database"
 	"github.com/notLeoHirano/bartr/handlers"
+	"github.com/notLeoHirano/bartr/mailer"
+	"github.com/notLeoHirano/bartr/middleware"
 	"github.com/notLeoHirano/bartr/models"
+	"github.com/notLeoHirano/bartr/ranking"
 	"github.com/notLeoHirano/bartr/service"
+	"github.com/notLeoHirano/bartr/storage"
 	"github.com/notLeoHirano/bartr/store"
 )
 
 var testHandler *handlers.Handler
+var testService *service.Service
 var testDB *database.DB
 
 func TestMain(m *testing.M) {
@@ -37,9 +56,14 @@ func setupTest(t *testing.T) {
 		t.Fatal("Failed to initialize test database:", err)
 	}
 
+	blobs, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal("Failed to create photo storage:", err)
+	}
+
 	st := store.New(testDB.DB)
-	svc := service.New(st)
-	testHandler = handlers.New(svc)
+	testService = service.New(st, service.WithBlobStore(blobs))
+	testHandler = handlers.New(testService)
 }
 
 func teardownTest() {
@@ -52,8 +76,8 @@ func TestCreateItem_Success(t *testing.T) {
 	setupTest(t)
 	defer teardownTest()
 
-	router := gin.New()
-	router.POST("/items", testHandler.CreateItem)
+	// Listing items needs a verified account, such as seeded Alice's
+	router := makeAuthRouter(testHandler.CreateItem, "/items", "POST", 1)
 
 	item := models.Item{
 		UserID:      1,
@@ -136,14 +160,16 @@ func TestGetItems_Success(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", w.Code)
 	}
 
-	var items []models.ItemWithOwner
-	json.Unmarshal(w.Body.Bytes(), &items)
+	var page models.Page[models.ItemWithOwner]
+	json.Unmarshal(w.Body.Bytes(), &page)
+	items := page.Data
 
 	if len(items) == 0 {
-		t.Error("Expected at least one item")
+		t.Fatal("Expected at least one item")
 	}
 
-	if items[len(items)-1].Title != "Test Item" {
+	// Newest first, with ties on created_at broken by the newer ID
+	if items[0].Title != "Test Item" {
 		t.Errorf("Expected title 'Test Item', got '%s'", items[0].Title)
 	}
 }
@@ -193,6 +219,10 @@ func makeAuthRouter(handler gin.HandlerFunc, route string, method string, userID
 	switch method {
 	case "POST":
 		r.POST(route, handler)
+	case "PUT":
+		r.PUT(route, handler)
+	case "PATCH":
+		r.PATCH(route, handler)
 	case "DELETE":
 		r.DELETE(route, handler)
 	case "GET":
@@ -321,4 +351,2257 @@ func TestDeleteItem_NotFound(t *testing.T) {
     if response["error"] != expectedError {
         t.Errorf("Expected error '%s', got '%s'", expectedError, response["error"])
     }
-}
\ No newline at end of file
+}
+
+func TestUpdateItem_PatchRecordsRevisions(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	result, _ := testDB.Exec("INSERT INTO items (user_id, title, description, category) VALUES (?, ?, ?, ?)",
+		1, "Old Title", "Old description", "Misc")
+	id, _ := result.LastInsertId()
+	itemID := strconv.FormatInt(id, 10)
+
+	// Bob swipes first so the edit happens after his swipe
+	testDB.Exec("INSERT INTO swipes (user_id, item_id, direction) VALUES (?, ?, ?)", 2, id, "right")
+
+	patchRouter := makeAuthRouter(testHandler.UpdateItem, "/items/:id", "PATCH", 1)
+	w := performRequest(patchRouter, "PATCH", "/items/"+itemID, []byte(`{"title": "New Title"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var item models.Item
+	json.Unmarshal(w.Body.Bytes(), &item)
+	if item.Title != "New Title" || item.Description != "Old description" {
+		t.Errorf("Expected only the title to change, got %+v", item)
+	}
+
+	// The swipe must survive the edit
+	var swipes int
+	testDB.QueryRow("SELECT COUNT(*) FROM swipes WHERE item_id = ?", id).Scan(&swipes)
+	if swipes != 1 {
+		t.Errorf("Expected swipe to be kept, got %d swipes", swipes)
+	}
+
+	historyRouter := makeAuthRouter(testHandler.GetItemRevisions, "/items/:id/revisions", "GET", 2)
+	w = performRequest(historyRouter, "GET", "/items/"+itemID+"/revisions", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var history models.ItemHistory
+	json.Unmarshal(w.Body.Bytes(), &history)
+	if len(history.Revisions) != 1 {
+		t.Fatalf("Expected 1 revision, got %d", len(history.Revisions))
+	}
+	rev := history.Revisions[0]
+	if rev.Field != "title" || rev.OldValue != "Old Title" || rev.NewValue != "New Title" {
+		t.Errorf("Unexpected revision: %+v", rev)
+	}
+	if !history.ChangedSinceSwipe {
+		t.Error("Expected item to be reported as changed since Bob's swipe")
+	}
+}
+
+func TestUpdateItem_NotOwner(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	result, _ := testDB.Exec("INSERT INTO items (user_id, title) VALUES (?, ?)", 1, "Alice's Item")
+	id, _ := result.LastInsertId()
+
+	putRouter := makeAuthRouter(testHandler.ReplaceItem, "/items/:id", "PUT", 2)
+	w := performRequest(putRouter, "PUT", "/items/"+strconv.FormatInt(id, 10), []byte(`{"title": "Stolen"}`))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var title string
+	testDB.QueryRow("SELECT title FROM items WHERE id = ?", id).Scan(&title)
+	if title != "Alice's Item" {
+		t.Errorf("Expected title to be unchanged, got '%s'", title)
+	}
+}
+
+// createMatch makes users 1 and 2 like each other's new items and returns the
+// item IDs and the resulting match ID.
+func createMatch(t *testing.T) (int, int, int) {
+	t.Helper()
+
+	result1, _ := testDB.Exec("INSERT INTO items (user_id, title) VALUES (?, ?)", 1, "Alice's Camera")
+	item1ID, _ := result1.LastInsertId()
+	result2, _ := testDB.Exec("INSERT INTO items (user_id, title) VALUES (?, ?)", 2, "Bob's Tent")
+	item2ID, _ := result2.LastInsertId()
+
+	bobRouter := makeAuthRouter(testHandler.CreateSwipe, "/swipes", "POST", 2)
+	w := performRequest(bobRouter, "POST", "/swipes", []byte(`{"item_id": `+strconv.FormatInt(item1ID, 10)+`, "direction": "right"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Bob swipe failed: %d %s", w.Code, w.Body.String())
+	}
+	aliceRouter := makeAuthRouter(testHandler.CreateSwipe, "/swipes", "POST", 1)
+	w = performRequest(aliceRouter, "POST", "/swipes", []byte(`{"item_id": `+strconv.FormatInt(item2ID, 10)+`, "direction": "right"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Alice swipe failed: %d %s", w.Code, w.Body.String())
+	}
+
+	var matchID int
+	if err := testDB.QueryRow("SELECT id FROM matches WHERE item1_id IN (?, ?)", item1ID, item2ID).Scan(&matchID); err != nil {
+		t.Fatalf("Expected a match: %v", err)
+	}
+	return int(item1ID), int(item2ID), matchID
+}
+
+func itemStatus(id int) string {
+	var status string
+	testDB.QueryRow("SELECT status FROM items WHERE id = ?", id).Scan(&status)
+	return status
+}
+
+func TestMatchLifecycle_ItemStatuses(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	item1ID, item2ID, matchID := createMatch(t)
+	path := "/matches/" + strconv.Itoa(matchID)
+
+	// Charlie is not part of the match
+	charlieRouter := makeAuthRouter(testHandler.AcceptMatch, "/matches/:match_id/accept", "POST", 3)
+	if w := performRequest(charlieRouter, "POST", path+"/accept", nil); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for outsider, got %d", w.Code)
+	}
+
+	acceptRouter := makeAuthRouter(testHandler.AcceptMatch, "/matches/:match_id/accept", "POST", 2)
+	if w := performRequest(acceptRouter, "POST", path+"/accept", nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on accept, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if itemStatus(item1ID) != models.ItemStatusPending || itemStatus(item2ID) != models.ItemStatusPending {
+		t.Errorf("Expected both items pending, got %s and %s", itemStatus(item1ID), itemStatus(item2ID))
+	}
+
+	// The owner cannot relist an item the match has reserved
+	statusRouter := makeAuthRouter(testHandler.SetItemStatus, "/items/:id/status", "PUT", 1)
+	w := performRequest(statusRouter, "PUT", "/items/"+strconv.Itoa(item1ID)+"/status", []byte(`{"status": "available"}`))
+	if w.Code != http.StatusConflict || itemStatus(item1ID) != models.ItemStatusPending {
+		t.Errorf("Expected 409 relisting a pending item, got %d", w.Code)
+	}
+
+	// Pending items drop out of Charlie's deck
+	itemsRouter := makeAuthRouter(testHandler.GetItems, "/items", "GET", 3)
+	w = performRequest(itemsRouter, "GET", "/items?exclude_own=true", nil)
+	var page models.Page[models.ItemWithOwner]
+	json.Unmarshal(w.Body.Bytes(), &page)
+	for _, item := range page.Data {
+		if item.ID == item1ID || item.ID == item2ID {
+			t.Errorf("Pending item %d should not be in the deck", item.ID)
+		}
+	}
+
+	// Completing takes a confirmation from each side
+	completeRouter := makeAuthRouter(testHandler.CompleteMatch, "/matches/:match_id/complete", "POST", 1)
+	w = performRequest(completeRouter, "POST", path+"/complete", nil)
+	var match models.Match
+	json.Unmarshal(w.Body.Bytes(), &match)
+	if w.Code != http.StatusOK || match.Status != models.MatchStatusAccepted || match.AcceptedAt == nil {
+		t.Fatalf("Expected the match to wait for Bob, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if itemStatus(item1ID) != models.ItemStatusPending {
+		t.Errorf("Expected items to stay pending until both confirm, got %s", itemStatus(item1ID))
+	}
+	if w := performRequest(completeRouter, "POST", path+"/complete", nil); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 confirming twice, got %d", w.Code)
+	}
+
+	bobComplete := makeAuthRouter(testHandler.CompleteMatch, "/matches/:match_id/complete", "POST", 2)
+	w = performRequest(bobComplete, "POST", path+"/complete", nil)
+	var completed models.Match
+	json.Unmarshal(w.Body.Bytes(), &completed)
+	if w.Code != http.StatusOK || completed.Status != models.MatchStatusCompleted || completed.CompletedAt == nil {
+		t.Fatalf("Expected completed, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if itemStatus(item1ID) != models.ItemStatusTraded || itemStatus(item2ID) != models.ItemStatusTraded {
+		t.Errorf("Expected both items traded, got %s and %s", itemStatus(item1ID), itemStatus(item2ID))
+	}
+}
+
+func TestMatchLifecycle_DeclineAndCancel(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	transition := func(action string, userID, matchID int) (*httptest.ResponseRecorder, models.Match) {
+		router := makeAuthRouter(map[string]gin.HandlerFunc{
+			"accept":  testHandler.AcceptMatch,
+			"decline": testHandler.DeclineMatch,
+			"cancel":  testHandler.CancelMatch,
+		}[action], "/matches/:match_id/"+action, "POST", userID)
+		w := performRequest(router, "POST", "/matches/"+strconv.Itoa(matchID)+"/"+action, nil)
+		var match models.Match
+		json.Unmarshal(w.Body.Bytes(), &match)
+		return w, match
+	}
+
+	item1ID, _, matchID := createMatch(t)
+
+	// A proposed match cannot be cancelled, only declined
+	if w, _ := transition("cancel", 1, matchID); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 cancelling a proposed match, got %d", w.Code)
+	}
+	w, match := transition("decline", 2, matchID)
+	if w.Code != http.StatusOK || match.Status != models.MatchStatusDeclined || match.DeclinedAt == nil {
+		t.Fatalf("Expected declined, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if itemStatus(item1ID) != models.ItemStatusAvailable {
+		t.Errorf("Expected item to stay available, got %s", itemStatus(item1ID))
+	}
+	if w, _ := transition("accept", 1, matchID); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 accepting a declined match, got %d", w.Code)
+	}
+
+	// Cancelling an accepted match puts the items back on offer
+	item3ID, item4ID, matchID := createMatch(t)
+	transition("accept", 1, matchID)
+	if w, _ := transition("cancel", 3, matchID); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for outsider, got %d", w.Code)
+	}
+	w, match = transition("cancel", 2, matchID)
+	if w.Code != http.StatusOK || match.Status != models.MatchStatusCancelled || match.CancelledAt == nil {
+		t.Fatalf("Expected cancelled, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if itemStatus(item3ID) != models.ItemStatusAvailable || itemStatus(item4ID) != models.ItemStatusAvailable {
+		t.Errorf("Expected both items available, got %s and %s", itemStatus(item3ID), itemStatus(item4ID))
+	}
+}
+
+func TestSwipe_WithdrawnItemNotMatchable(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	result, _ := testDB.Exec("INSERT INTO items (user_id, title) VALUES (?, ?)", 1, "Alice's Kettle")
+	id, _ := result.LastInsertId()
+	itemID := strconv.FormatInt(id, 10)
+
+	statusRouter := makeAuthRouter(testHandler.SetItemStatus, "/items/:id/status", "PUT", 1)
+	w := performRequest(statusRouter, "PUT", "/items/"+itemID+"/status", []byte(`{"status": "withdrawn"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// Owners cannot jump straight to traded
+	w = performRequest(statusRouter, "PUT", "/items/"+itemID+"/status", []byte(`{"status": "traded"}`))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+
+	swipeRouter := makeAuthRouter(testHandler.CreateSwipe, "/swipes", "POST", 2)
+	w = performRequest(swipeRouter, "POST", "/swipes", []byte(`{"item_id": `+itemID+`, "direction": "right"}`))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 when swiping a withdrawn item, got %d", w.Code)
+	}
+}
+
+// uploadPhotos posts the given files as the multipart "photos" field.
+func uploadPhotos(router *gin.Engine, path string, files map[string][]byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, data := range files {
+		part, _ := mw.CreateFormFile("photos", name)
+		part.Write(data)
+	}
+	mw.Close()
+
+	req, _ := http.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// jpegWithEXIF encodes a small JPEG and splices in an APP1 EXIF segment
+// carrying a GPS-looking marker string.
+func jpegWithEXIF(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 6), 100, 150, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Minimal little-endian TIFF with an orientation tag of 6 (rotate 90 CW)
+	tiff := []byte{'I', 'I', 42, 0, 8, 0, 0, 0, 1, 0, 0x12, 0x01, 3, 0, 1, 0, 0, 0, 6, 0, 0, 0, 0, 0, 0, 0}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	payload = append(payload, []byte("GPSSECRET")...)
+	segment := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	segment = append(segment, payload...)
+
+	data := buf.Bytes()
+	return append(append([]byte{0xFF, 0xD8}, segment...), data[2:]...)
+}
+
+func TestUploadItemPhotos_StripsMetadata(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	result, _ := testDB.Exec("INSERT INTO items (user_id, title) VALUES (?, ?)", 1, "Alice's Lamp")
+	id, _ := result.LastInsertId()
+	path := "/items/" + strconv.FormatInt(id, 10) + "/photos"
+
+	router := makeAuthRouter(testHandler.UploadItemPhotos, "/items/:id/photos", "POST", 1)
+	w := uploadPhotos(router, path, map[string][]byte{"lamp.png": jpegWithEXIF(t)})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var photos []models.ItemPhoto
+	json.Unmarshal(w.Body.Bytes(), &photos)
+	if len(photos) != 1 {
+		t.Fatalf("Expected 1 photo, got %d", len(photos))
+	}
+	// Content wins over the misleading .png extension, and the EXIF
+	// orientation is applied to the pixels
+	if photos[0].ContentType != "image/jpeg" || photos[0].Width != 20 || photos[0].Height != 40 {
+		t.Errorf("Unexpected photo metadata: %+v", photos[0])
+	}
+
+	serveRouter := gin.New()
+	serveRouter.GET("/photos/:id", testHandler.ServePhoto)
+	w = performRequest(serveRouter, "GET", photos[0].URL, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 serving photo, got %d", w.Code)
+	}
+	if bytes.Contains(w.Body.Bytes(), []byte("Exif")) || bytes.Contains(w.Body.Bytes(), []byte("GPSSECRET")) {
+		t.Error("Expected EXIF metadata to be stripped")
+	}
+	if w.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("Expected image/jpeg, got %s", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestUploadItemPhotos_RejectsNonImages(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	result, _ := testDB.Exec("INSERT INTO items (user_id, title) VALUES (?, ?)", 1, "Alice's Lamp")
+	id, _ := result.LastInsertId()
+	path := "/items/" + strconv.FormatInt(id, 10) + "/photos"
+
+	router := makeAuthRouter(testHandler.UploadItemPhotos, "/items/:id/photos", "POST", 1)
+	w := uploadPhotos(router, path, map[string][]byte{"evil.jpg": []byte("<html><script>alert(1)</script></html>")})
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected 415, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// Only the owner may upload
+	bobRouter := makeAuthRouter(testHandler.UploadItemPhotos, "/items/:id/photos", "POST", 2)
+	w = uploadPhotos(bobRouter, path, map[string][]byte{"lamp.jpg": jpegWithEXIF(t)})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", w.Code)
+	}
+
+	// A GIF whose frames would decode past the pixel limit is refused up
+	// front, while a small animation is fine
+	w = uploadPhotos(router, path, map[string][]byte{"bomb.gif": animatedGIF(t, 6000, 6000, 2)})
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected 415 for a GIF bomb, got %d", w.Code)
+	}
+
+	var count int
+	testDB.QueryRow("SELECT COUNT(*) FROM item_photos").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected no stored photos, got %d", count)
+	}
+
+	if w := uploadPhotos(router, path, map[string][]byte{"wave.gif": animatedGIF(t, 30, 20, 3)}); w.Code != http.StatusCreated {
+		t.Errorf("Expected 201 for a small animated GIF, got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
+
+// animatedGIF encodes an animation of 2x2 frames on a width x height
+// logical screen.
+func animatedGIF(t *testing.T, width, height, frames int) []byte {
+	t.Helper()
+
+	g := &gif.GIF{Config: image.Config{Width: width, Height: height, ColorModel: color.Palette(palette.Plan9)}}
+	for i := 0; i < frames; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 2, 2), palette.Plan9))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUploadItemPhotos_GeneratesVariants(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	result, _ := testDB.Exec("INSERT INTO items (user_id, title) VALUES (?, ?)", 1, "Alice's Poster")
+	id, _ := result.LastInsertId()
+
+	img := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	for x := 0; x < 1000; x++ {
+		for y := 0; y < 500; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 80, 255})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+
+	router := makeAuthRouter(testHandler.UploadItemPhotos, "/items/:id/photos", "POST", 1)
+	w := uploadPhotos(router, "/items/"+strconv.FormatInt(id, 10)+"/photos", map[string][]byte{"poster.png": buf.Bytes()})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// The deck exposes the cover photo's variants
+	itemsRouter := makeAuthRouter(testHandler.GetItems, "/items", "GET", 2)
+	w = performRequest(itemsRouter, "GET", "/items", nil)
+	var page models.Page[models.ItemWithOwner]
+	json.Unmarshal(w.Body.Bytes(), &page)
+	items := page.Data
+
+	var poster *models.ItemWithOwner
+	for i := range items {
+		if items[i].ID == int(id) {
+			poster = &items[i]
+		}
+	}
+	if poster == nil {
+		t.Fatal("Expected the poster in the item list")
+	}
+	if poster.ThumbnailURL == "" || poster.CardURL == "" || poster.ThumbnailURL == poster.CardURL {
+		t.Fatalf("Expected distinct thumbnail and card URLs, got %q and %q", poster.ThumbnailURL, poster.CardURL)
+	}
+
+	serveRouter := gin.New()
+	serveRouter.GET("/photos/:id", testHandler.ServePhoto)
+
+	sizes := map[string]image.Point{
+		poster.ThumbnailURL: {200, 200},
+		poster.CardURL:      {800, 400},
+	}
+	for url, want := range sizes {
+		w := performRequest(serveRouter, "GET", url, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d", url, w.Code)
+		}
+		cfg, _, err := image.DecodeConfig(w.Body)
+		if err != nil {
+			t.Fatalf("Failed to decode %s: %v", url, err)
+		}
+		if cfg.Width != want.X || cfg.Height != want.Y {
+			t.Errorf("Expected %s to be %dx%d, got %dx%d", url, want.X, want.Y, cfg.Width, cfg.Height)
+		}
+	}
+}
+
+func TestSearchItems(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	result, _ := testDB.Exec("INSERT INTO items (user_id, title, description, category) VALUES (?, ?, ?, ?)",
+		2, "Electric Telecaster", "Comes with a <b>free</b> amp", "Music")
+	guitarID, _ := result.LastInsertId()
+	result, _ = testDB.Exec("INSERT INTO items (user_id, title, description, category) VALUES (?, ?, ?, ?)",
+		2, "Telecaster Strings", "Unopened pack", "Music")
+	stringsID, _ := result.LastInsertId()
+	testDB.Exec("INSERT INTO items (user_id, title, description, category) VALUES (?, ?, ?, ?)",
+		1, "Alice's Telecaster Stand", "Folding stand", "Music")
+
+	// Alice already passed on the strings
+	testDB.Exec("INSERT INTO swipes (user_id, item_id, direction) VALUES (?, ?, ?)", 1, stringsID, "left")
+
+	router := makeAuthRouter(testHandler.SearchItems, "/items/search", "GET", 1)
+	w := performRequest(router, "GET", "/items/search?q=telec&exclude_own=true", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var results []models.ItemSearchResult
+	json.Unmarshal(w.Body.Bytes(), &results)
+	if len(results) != 1 || results[0].ID != int(guitarID) {
+		t.Fatalf("Expected only the electric telecaster, got %+v", results)
+	}
+	if results[0].Snippet != "Electric <mark>Telecaster</mark>" {
+		t.Errorf("Unexpected snippet: %q", results[0].Snippet)
+	}
+
+	// Description matches are highlighted with the item text escaped
+	w = performRequest(router, "GET", "/items/search?q=amp", nil)
+	json.Unmarshal(w.Body.Bytes(), &results)
+	if len(results) != 1 || results[0].Snippet != "Comes with a &lt;b&gt;free&lt;/b&gt; <mark>amp</mark>" {
+		t.Errorf("Unexpected results for 'amp': %+v", results)
+	}
+
+	// Edits are picked up by the index triggers
+	testDB.Exec("UPDATE items SET title = ? WHERE id = ?", "Electric Bass", guitarID)
+	w = performRequest(router, "GET", "/items/search?q=bass", nil)
+	json.Unmarshal(w.Body.Bytes(), &results)
+	if len(results) != 1 || results[0].ID != int(guitarID) {
+		t.Errorf("Expected the renamed item to match 'bass', got %+v", results)
+	}
+
+	// Operators are stripped rather than passed through to FTS5
+	w = performRequest(router, "GET", "/items/search?q=%22%29%2A+OR", nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for punctuation-heavy query, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	w = performRequest(router, "GET", "/items/search?q=+", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for empty query, got %d", w.Code)
+	}
+}
+
+func TestPagination_ItemsAndComments(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	// 6 seeded items plus 5 more, all sharing a created_at second
+	for i := 0; i < 5; i++ {
+		testDB.Exec("INSERT INTO items (user_id, title) VALUES (?, ?)", 3, "Paged Item "+strconv.Itoa(i))
+	}
+
+	router := makeAuthRouter(testHandler.GetItems, "/items", "GET", 1)
+	seen := map[int]bool{}
+	cursor := ""
+	pages := 0
+	for {
+		w := performRequest(router, "GET", "/items?limit=4&cursor="+cursor, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+		}
+
+		var page models.Page[models.ItemWithOwner]
+		json.Unmarshal(w.Body.Bytes(), &page)
+		if len(page.Data) > 4 {
+			t.Fatalf("Expected at most 4 items per page, got %d", len(page.Data))
+		}
+		for _, item := range page.Data {
+			if seen[item.ID] {
+				t.Errorf("Item %d returned twice", item.ID)
+			}
+			seen[item.ID] = true
+		}
+
+		pages++
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	if len(seen) != 11 || pages != 3 {
+		t.Errorf("Expected 11 items over 3 pages, got %d items over %d pages", len(seen), pages)
+	}
+
+	w := performRequest(router, "GET", "/items?cursor=not-a-cursor", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad cursor, got %d", w.Code)
+	}
+
+	// Comments page oldest first
+	_, _, matchID := createMatch(t)
+	for i := 0; i < 3; i++ {
+		testDB.Exec("INSERT INTO comments (match_id, user_id, content) VALUES (?, ?, ?)", matchID, 1, "Comment "+strconv.Itoa(i))
+	}
+
+	commentsRouter := makeAuthRouter(testHandler.GetComments, "/matches/:match_id/comments", "GET", 1)
+	path := "/matches/" + strconv.Itoa(matchID) + "/comments"
+	w = performRequest(commentsRouter, "GET", path+"?limit=2", nil)
+	var first models.Page[models.Comment]
+	json.Unmarshal(w.Body.Bytes(), &first)
+	if len(first.Data) != 2 || first.Data[0].Content != "Comment 0" || first.NextCursor == "" {
+		t.Fatalf("Unexpected first comment page: %+v", first)
+	}
+
+	w = performRequest(commentsRouter, "GET", path+"?limit=2&cursor="+first.NextCursor, nil)
+	var second models.Page[models.Comment]
+	json.Unmarshal(w.Body.Bytes(), &second)
+	if len(second.Data) != 1 || second.Data[0].Content != "Comment 2" || second.NextCursor != "" {
+		t.Errorf("Unexpected second comment page: %+v", second)
+	}
+}
+
+func TestGetItems_Filters(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	fixtures := []struct {
+		userID    int
+		title     string
+		category  string
+		condition string
+		value     interface{}
+		createdAt string
+	}{
+		{2, "Kettle", "kitchen", "good", 40, "2024-06-01 10:00:00"},
+		{2, "Mixer", "kitchen", "like_new", 300, "2020-01-01 00:00:00"},
+		{3, "Novel", "books", "fair", 5, "2024-06-01 10:00:00"},
+		{3, "Wok", "kitchen", "poor", nil, "2024-06-01 10:00:00"},
+	}
+	for _, f := range fixtures {
+		_, err := testDB.Exec(`
+			INSERT INTO items (user_id, title, category, category_id, condition, estimated_value, created_at)
+			SELECT ?, ?, name, id, ?, ?, ? FROM categories WHERE slug = ?
+		`, f.userID, f.title, f.condition, f.value, f.createdAt, f.category)
+		if err != nil {
+			t.Fatalf("Failed to insert %s: %v", f.title, err)
+		}
+	}
+
+	router := makeAuthRouter(testHandler.GetItems, "/items", "GET", 1)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"category by slug", "category=kitchen", []string{"Kettle", "Mixer", "Wok"}},
+		{"category by name, any case", "category=KITCHEN", []string{"Kettle", "Mixer", "Wok"}},
+		{"parent category includes subcategories", "category=home", []string{"Kettle", "Mixer", "Wok", "Vintage Lamp", "Desk Chair"}},
+		{"comma-separated categories", "category=Kitchen,Books", []string{"Kettle", "Mixer", "Novel", "Wok", "Cookbook"}},
+		{"repeated categories", "category=kitchen&category=books", []string{"Kettle", "Mixer", "Novel", "Wok", "Cookbook"}},
+		{"conditions", "condition=good,like_new", []string{"Kettle", "Mixer"}},
+		{"min value", "min_value=10", []string{"Kettle", "Mixer"}},
+		{"max value", "max_value=50", []string{"Kettle", "Novel"}},
+		{"value range", "min_value=10&max_value=100", []string{"Kettle"}},
+		{"posted since date", "posted_since=2021-01-01&category=kitchen", []string{"Kettle", "Wok"}},
+		{"posted since timestamp", "posted_since=2024-06-01T10:00:01Z&category=kitchen", []string{}},
+		{"exclude owners", "exclude_owners=2&category=kitchen", []string{"Wok"}},
+		{"exclude several owners and own", "exclude_owners=2,3&exclude_own=true", []string{}},
+		{"category, condition and owner", "category=kitchen,books&condition=poor,fair&exclude_owners=3", []string{}},
+		{"category, condition and value", "category=kitchen&condition=good,like_new,poor&max_value=100", []string{"Kettle"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := performRequest(router, "GET", "/items?"+tt.query, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+			}
+
+			var page models.Page[models.ItemWithOwner]
+			json.Unmarshal(w.Body.Bytes(), &page)
+
+			got := map[string]bool{}
+			for _, item := range page.Data {
+				got[item.Title] = true
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for _, title := range tt.want {
+				if !got[title] {
+					t.Errorf("Expected %q in %v", title, got)
+				}
+			}
+		})
+	}
+
+	for _, query := range []string{
+		"category=outdoor-gear",
+		"condition=mint",
+		"min_value=abc",
+		"max_value=-1",
+		"min_value=50&max_value=10",
+		"posted_since=yesterday",
+		"exclude_owners=bob",
+	} {
+		w := performRequest(router, "GET", "/items?"+query, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestCreateItem_ConditionAndValue(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	router := makeAuthRouter(testHandler.CreateItem, "/items", "POST", 1)
+
+	w := performRequest(router, "POST", "/items", []byte(`{"title": "Amp", "condition": "mint"}`))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown condition, got %d", w.Code)
+	}
+
+	w = performRequest(router, "POST", "/items", []byte(`{"title": "Amp", "condition": "good", "estimated_value": 120}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var item models.Item
+	json.Unmarshal(w.Body.Bytes(), &item)
+
+	// Clearing the value with an explicit null is recorded as a revision
+	patchRouter := makeAuthRouter(testHandler.UpdateItem, "/items/:id", "PATCH", 1)
+	w = performRequest(patchRouter, "PATCH", "/items/"+strconv.Itoa(item.ID), []byte(`{"estimated_value": null}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var updated models.Item
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if updated.EstimatedValue != nil || updated.Condition != "good" {
+		t.Errorf("Expected value cleared and condition kept, got %+v", updated)
+	}
+
+	var oldValue string
+	testDB.QueryRow("SELECT old_value FROM item_revisions WHERE item_id = ? AND field = 'estimated_value'", item.ID).Scan(&oldValue)
+	if oldValue != "120" {
+		t.Errorf("Expected revision from 120, got %q", oldValue)
+	}
+}
+
+func TestCategories(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	router := gin.New()
+	router.GET("/categories", testHandler.GetCategories)
+	w := performRequest(router, "GET", "/categories", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	var tree []models.Category
+	json.Unmarshal(w.Body.Bytes(), &tree)
+	var home *models.Category
+	for i := range tree {
+		if tree[i].ParentID != nil {
+			t.Errorf("Top-level category %q has a parent", tree[i].Slug)
+		}
+		if tree[i].Slug == "home" {
+			home = &tree[i]
+		}
+	}
+	if home == nil || len(home.Children) == 0 || home.Children[0].Slug != "furniture" {
+		t.Fatalf("Expected home with furniture beneath it, got %+v", home)
+	}
+
+	// Categories resolve by slug or name and are stored by display name
+	createRouter := makeAuthRouter(testHandler.CreateItem, "/items", "POST", 1)
+	w = performRequest(createRouter, "POST", "/items", []byte(`{"title": "Drum Kit", "category": "musical-instruments"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var item models.Item
+	json.Unmarshal(w.Body.Bytes(), &item)
+	if item.Category != "Musical Instruments" || item.CategoryID == nil {
+		t.Errorf("Expected canonical category, got %q (%v)", item.Category, item.CategoryID)
+	}
+
+	// Aliases resolve the same way the startup mapping below does
+	w = performRequest(createRouter, "POST", "/items", []byte(`{"title": "Monopoly", "category": "Board Games"}`))
+	json.Unmarshal(w.Body.Bytes(), &item)
+	if w.Code != http.StatusCreated || item.Category != "Toys & Games" {
+		t.Errorf("Expected the alias to resolve to Toys & Games, got %d %q", w.Code, item.Category)
+	}
+
+	w = performRequest(createRouter, "POST", "/items", []byte(`{"title": "Gnome", "category": "Garden Ornaments"}`))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown category, got %d", w.Code)
+	}
+
+	// Free-text categories from before the taxonomy are mapped on startup
+	testDB.Exec("INSERT INTO items (user_id, title, category) VALUES (2, 'Chess Set', 'toys and games')")
+	testDB.Exec("INSERT INTO items (user_id, title, category) VALUES (2, 'Gnome', 'Garden Ornaments')")
+	if err := testDB.Init(); err != nil {
+		t.Fatal("Failed to re-run migrations:", err)
+	}
+
+	for title, want := range map[string]string{"Chess Set": "Toys & Games", "Gnome": "Other", "Acoustic Guitar": "Music"} {
+		var category string
+		var categoryID *int
+		testDB.QueryRow("SELECT category, category_id FROM items WHERE title = ?", title).Scan(&category, &categoryID)
+		if category != want || categoryID == nil {
+			t.Errorf("Expected %s in %q, got %q (%v)", title, want, category, categoryID)
+		}
+	}
+
+	var original string
+	testDB.QueryRow(`
+		SELECT r.old_value FROM item_revisions r JOIN items i ON i.id = r.item_id
+		WHERE i.title = 'Gnome' AND r.field = 'category'
+	`).Scan(&original)
+	if original != "Garden Ornaments" {
+		t.Errorf("Expected the original category to be kept as a revision, got %q", original)
+	}
+}
+
+func TestGetItems_RadiusFilter(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	// Alice in Berlin, Bob in Potsdam (about 27 km away), Charlie has no location
+	locationRouter := makeAuthRouter(testHandler.SetLocation, "/me/location", "PUT", 1)
+	w := performRequest(locationRouter, "PUT", "/me/location", []byte(`{"latitude": 52.52, "longitude": 13.405, "display_area": "Mitte"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	w = performRequest(makeAuthRouter(testHandler.SetLocation, "/me/location", "PUT", 2),
+		"PUT", "/me/location", []byte(`{"latitude": 52.39, "longitude": 13.06, "display_area": "Potsdam"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	w = performRequest(locationRouter, "PUT", "/me/location", []byte(`{"latitude": 95, "longitude": 13.4}`))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid latitude, got %d", w.Code)
+	}
+
+	// Charlie's items can carry their own location
+	createRouter := makeAuthRouter(testHandler.CreateItem, "/items", "POST", 3)
+	for _, body := range []string{
+		`{"title": "Bike Lock", "location": {"latitude": 52.53, "longitude": 13.41}, "display_area": "Wedding"}`,
+		`{"title": "Beer Stein", "location": {"latitude": 48.14, "longitude": 11.58}}`,
+	} {
+		if w := performRequest(createRouter, "POST", "/items", []byte(body)); w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+		}
+	}
+
+	router := makeAuthRouter(testHandler.GetItems, "/items", "GET", 1)
+	feed := func(query string) map[string]models.ItemWithOwner {
+		t.Helper()
+		w := performRequest(router, "GET", "/items?exclude_own=true&"+query, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %q, got %d. Body: %s", query, w.Code, w.Body.String())
+		}
+		if bytes.Contains(w.Body.Bytes(), []byte("latitude")) {
+			t.Errorf("Feed exposes exact coordinates: %s", w.Body.String())
+		}
+		var page models.Page[models.ItemWithOwner]
+		json.Unmarshal(w.Body.Bytes(), &page)
+		items := map[string]models.ItemWithOwner{}
+		for _, item := range page.Data {
+			items[item.Title] = item
+		}
+		return items
+	}
+
+	near := feed("radius_km=5")
+	if len(near) != 1 || near["Bike Lock"].DistanceKm == nil || *near["Bike Lock"].DistanceKm != 1 {
+		t.Fatalf("Expected only Bike Lock at 1 km, got %+v", near)
+	}
+	if near["Bike Lock"].DisplayArea != "Wedding" {
+		t.Errorf("Expected the item's own display area, got %q", near["Bike Lock"].DisplayArea)
+	}
+
+	wider := feed("radius_km=50")
+	if len(wider) != 3 {
+		t.Fatalf("Expected Bike Lock and Bob's two items, got %v", wider)
+	}
+	guitar := wider["Acoustic Guitar"]
+	if guitar.DistanceKm == nil || *guitar.DistanceKm != 25 || guitar.DisplayArea != "Potsdam" {
+		t.Errorf("Expected Bob's item at a rounded 25 km in Potsdam, got %v %q", guitar.DistanceKm, guitar.DisplayArea)
+	}
+
+	// The radius is compared with the rounded distance, so Potsdam (27.5 km
+	// exactly) is in at 26 km and out at 24 km, giving nothing finer away
+	if _, ok := feed("radius_km=26")["Acoustic Guitar"]; !ok {
+		t.Error("Expected Bob's item within a 26 km radius at its rounded 25 km")
+	}
+	if _, ok := feed("radius_km=24")["Acoustic Guitar"]; ok {
+		t.Error("Expected Bob's item outside a 24 km radius")
+	}
+
+	// Without a radius every item is listed; those without a location have no distance
+	all := feed("")
+	if _, ok := all["Board Games Bundle"]; !ok || all["Board Games Bundle"].DistanceKm != nil {
+		t.Errorf("Expected Charlie's unlocated item without a distance, got %+v", all["Board Games Bundle"])
+	}
+	if d := all["Beer Stein"].DistanceKm; d == nil || *d < 450 {
+		t.Errorf("Expected Beer Stein to be far away, got %v", d)
+	}
+
+	// Filtering by distance keeps pages full while skipping rows outside the circle
+	seen := 0
+	cursor := ""
+	for {
+		w := performRequest(router, "GET", "/items?exclude_own=true&radius_km=50&limit=1&cursor="+cursor, nil)
+		var page models.Page[models.ItemWithOwner]
+		json.Unmarshal(w.Body.Bytes(), &page)
+		seen += len(page.Data)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if seen != 3 {
+		t.Errorf("Expected 3 items when paging, got %d", seen)
+	}
+
+	for _, tc := range []struct {
+		userID int
+		query  string
+	}{
+		{1, "radius_km=0"},
+		{1, "radius_km=1000"},
+		{3, "radius_km=10"},
+	} {
+		w := performRequest(makeAuthRouter(testHandler.GetItems, "/items", "GET", tc.userID), "GET", "/items?"+tc.query, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for user %d with %q, got %d", tc.userID, tc.query, w.Code)
+		}
+	}
+
+	// An item's location can be moved and cleared later, without the
+	// coordinates ending up in its public revision history
+	stein := "/items/" + strconv.Itoa(all["Beer Stein"].ID)
+	patch := makeAuthRouter(testHandler.UpdateItem, "/items/:id", "PATCH", 3)
+	w = performRequest(patch, "PATCH", stein, []byte(`{"location": {"latitude": 52.5, "longitude": 13.42}, "display_area": "Kreuzberg"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 moving the item, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if moved, ok := feed("radius_km=5")["Beer Stein"]; !ok || moved.DisplayArea != "Kreuzberg" {
+		t.Errorf("Expected Beer Stein nearby in Kreuzberg once moved, got %+v", moved)
+	}
+	if w := performRequest(patch, "PATCH", stein, []byte(`{"location": {"latitude": 95, "longitude": 13.4}}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid coordinates, got %d", w.Code)
+	}
+
+	history := makeAuthRouter(testHandler.GetItemRevisions, "/items/:id/revisions", "GET", 1)
+	w = performRequest(history, "GET", stein+"/revisions", nil)
+	if bytes.Contains(w.Body.Bytes(), []byte("52.5")) || !bytes.Contains(w.Body.Bytes(), []byte(`"field":"location"`)) {
+		t.Errorf("Expected a location revision without coordinates, got %s", w.Body.String())
+	}
+
+	// Cleared, it is back at Charlie's location, which he has not set
+	performRequest(patch, "PATCH", stein, []byte(`{"location": null}`))
+	if _, ok := feed("radius_km=250")["Beer Stein"]; ok {
+		t.Error("Expected Beer Stein out of radius searches once its location is cleared")
+	}
+}
+
+func TestWants_SuggestNewListings(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	wantRouter := makeAuthRouter(testHandler.CreateWant, "/wants", "POST", 1)
+	for _, body := range []string{
+		`{"keywords": "telecaster"}`,
+		`{"category": "music", "keywords": "ukulele"}`,
+		`{"category": "furniture", "max_distance_km": 10}`,
+	} {
+		if w := performRequest(wantRouter, "POST", "/wants", []byte(body)); w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201 for %s, got %d. Body: %s", body, w.Code, w.Body.String())
+		}
+	}
+	for _, body := range []string{`{}`, `{"keywords": "!!"}`, `{"category": "spaceships"}`, `{"keywords": "x", "max_distance_km": -1}`} {
+		if w := performRequest(wantRouter, "POST", "/wants", []byte(body)); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, w.Code)
+		}
+	}
+
+	createRouter := makeAuthRouter(testHandler.CreateItem, "/items", "POST", 2)
+	for _, body := range []string{
+		`{"title": "Fender Telecaster", "category": "musical-instruments"}`,
+		`{"title": "Soprano Ukulele", "category": "musical-instruments"}`,
+		`{"title": "Ukulele Songbook", "category": "books"}`,
+		`{"title": "Oak Bookshelf", "category": "furniture"}`,
+	} {
+		if w := performRequest(createRouter, "POST", "/items", []byte(body)); w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+		}
+	}
+
+	router := makeAuthRouter(testHandler.GetItems, "/items", "GET", 1)
+	w := performRequest(router, "GET", "/items?exclude_own=true", nil)
+	var page models.Page[models.ItemWithOwner]
+	json.Unmarshal(w.Body.Bytes(), &page)
+
+	// The ukulele is newer, so it leads; the songbook is outside the music
+	// category and the bookshelf has no location to measure distance from
+	if len(page.Data) < 2 || page.Data[0].Title != "Soprano Ukulele" || page.Data[1].Title != "Fender Telecaster" {
+		t.Fatalf("Expected suggestions first, got %+v", page.Data)
+	}
+	if want := page.Data[0].SuggestedFor; want == nil || want.Keywords != "ukulele" || want.Category != "Music" {
+		t.Errorf("Expected the ukulele want as reason, got %+v", want)
+	}
+
+	seen := map[string]int{}
+	for _, item := range page.Data {
+		seen[item.Title]++
+		if item.SuggestedFor != nil && item.Title != "Soprano Ukulele" && item.Title != "Fender Telecaster" {
+			t.Errorf("Unexpected suggestion %q", item.Title)
+		}
+	}
+	if seen["Fender Telecaster"] != 1 || seen["Ukulele Songbook"] != 1 {
+		t.Errorf("Expected each item exactly once, got %v", seen)
+	}
+
+	// Suggestions count towards the limit and are not repeated on later pages
+	paged := map[string]int{}
+	cursor := ""
+	for {
+		w := performRequest(router, "GET", "/items?exclude_own=true&limit=3&cursor="+cursor, nil)
+		var next models.Page[models.ItemWithOwner]
+		json.Unmarshal(w.Body.Bytes(), &next)
+		if len(next.Data) > 3 {
+			t.Errorf("Expected at most 3 items per page, got %d", len(next.Data))
+		}
+		for _, item := range next.Data {
+			paged[item.Title]++
+		}
+		if next.NextCursor == "" {
+			break
+		}
+		cursor = next.NextCursor
+	}
+	if len(paged) != len(seen) {
+		t.Errorf("Expected the same items paged as on one page, got %v and %v", paged, seen)
+	}
+	for title, n := range paged {
+		if n != 1 {
+			t.Errorf("Expected %q on one page only, got %d", title, n)
+		}
+	}
+
+	// Suggestions leave the deck once swiped on, and wants can be deleted
+	swipeRouter := makeAuthRouter(testHandler.CreateSwipe, "/swipes", "POST", 1)
+	performRequest(swipeRouter, "POST", "/swipes", []byte(`{"item_id": `+strconv.Itoa(page.Data[0].ID)+`, "direction": "left"}`))
+
+	w = performRequest(router, "GET", "/items?exclude_own=true", nil)
+	var afterSwipe models.Page[models.ItemWithOwner]
+	json.Unmarshal(w.Body.Bytes(), &afterSwipe)
+	if afterSwipe.Data[0].Title != "Fender Telecaster" {
+		t.Errorf("Expected the remaining suggestion first, got %q", afterSwipe.Data[0].Title)
+	}
+
+	deleteRouter := makeAuthRouter(testHandler.DeleteWant, "/wants/:id", "DELETE", 1)
+	if w := performRequest(deleteRouter, "DELETE", "/wants/1", nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	w = performRequest(router, "GET", "/items?exclude_own=true", nil)
+	var afterDelete models.Page[models.ItemWithOwner]
+	json.Unmarshal(w.Body.Bytes(), &afterDelete)
+	for _, item := range afterDelete.Data {
+		if item.SuggestedFor != nil {
+			t.Errorf("Expected no suggestions after deleting the want, got %q", item.Title)
+		}
+	}
+	if w := performRequest(makeAuthRouter(testHandler.DeleteWant, "/wants/:id", "DELETE", 2), "DELETE", "/wants/2", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 deleting someone else's want, got %d", w.Code)
+	}
+}
+
+// swipeRight records a right swipe through the handler, as userID.
+func swipeRight(t *testing.T, userID, itemID int) {
+	t.Helper()
+	router := makeAuthRouter(testHandler.CreateSwipe, "/swipes", "POST", userID)
+	w := performRequest(router, "POST", "/swipes", []byte(`{"item_id": `+strconv.Itoa(itemID)+`, "direction": "right"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 swiping on item %d, got %d. Body: %s", itemID, w.Code, w.Body.String())
+	}
+}
+
+// createRing makes Alice, Bob and Charlie like each other's seeded items in a
+// circle: Alice wants Bob's Cookbook (3), Bob wants Charlie's Board Games
+// Bundle (5) and Charlie wants Alice's Vintage Lamp (1).
+func createRing(t *testing.T) {
+	t.Helper()
+	swipeRight(t, 1, 3)
+	swipeRight(t, 1, 4) // a second item of Bob's, ignored in favour of the lower id
+	swipeRight(t, 2, 5)
+	swipeRight(t, 3, 1)
+}
+
+func TestGroupMatch_RingTrade(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	createRing(t)
+	n, err := testService.FindTradeCycles()
+	if err != nil || n != 1 {
+		t.Fatalf("Expected one ring trade, got %d (%v)", n, err)
+	}
+	if n, _ := testService.FindTradeCycles(); n != 0 {
+		t.Errorf("Expected the ring not to be proposed twice, got %d", n)
+	}
+
+	w := performRequest(makeAuthRouter(testHandler.GetGroupMatches, "/group-matches", "GET", 1), "GET", "/group-matches", nil)
+	var groups []models.GroupMatch
+	json.Unmarshal(w.Body.Bytes(), &groups)
+	if len(groups) != 1 || len(groups[0].Participants) != 3 {
+		t.Fatalf("Expected one group of three, got %+v", groups)
+	}
+	alice := groups[0].Participants[0]
+	if alice.UserID != 1 || alice.ReceivesItemID != 3 || alice.GivesItemID != 1 {
+		t.Errorf("Expected Alice to give item 1 and get item 3, got %+v", alice)
+	}
+	path := "/group-matches/" + strconv.Itoa(groups[0].ID)
+
+	respond := func(action string, userID int) (*httptest.ResponseRecorder, models.GroupMatch) {
+		router := makeAuthRouter(map[string]gin.HandlerFunc{
+			"accept":   testHandler.AcceptGroupMatch,
+			"decline":  testHandler.DeclineGroupMatch,
+			"complete": testHandler.CompleteGroupMatch,
+		}[action], "/group-matches/:id/"+action, "POST", userID)
+		w := performRequest(router, "POST", path+"/"+action, nil)
+		var group models.GroupMatch
+		json.Unmarshal(w.Body.Bytes(), &group)
+		return w, group
+	}
+
+	if w, _ := respond("accept", 99); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for an outsider, got %d", w.Code)
+	}
+	if w, _ := respond("complete", 1); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 completing a proposed trade, got %d", w.Code)
+	}
+
+	respond("accept", 1)
+	if w, _ := respond("accept", 1); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 accepting twice, got %d", w.Code)
+	}
+	if _, group := respond("accept", 2); group.Status != models.MatchStatusProposed {
+		t.Errorf("Expected the trade to wait for Charlie, got %q", group.Status)
+	}
+	w, group := respond("accept", 3)
+	if w.Code != http.StatusOK || group.Status != models.MatchStatusAccepted {
+		t.Fatalf("Expected accepted, got %d %+v", w.Code, group)
+	}
+	for _, id := range []int{1, 3, 5} {
+		if status := itemStatus(id); status != models.ItemStatusPending {
+			t.Errorf("Expected item %d pending, got %q", id, status)
+		}
+	}
+	if status := itemStatus(4); status != models.ItemStatusAvailable {
+		t.Errorf("Expected item 4 untouched, got %q", status)
+	}
+
+	// Every participant has to confirm before the items are traded
+	for _, id := range []int{2, 3} {
+		if _, group := respond("complete", id); group.Status != models.MatchStatusAccepted {
+			t.Errorf("Expected the trade to wait for Alice, got %q", group.Status)
+		}
+	}
+	if w, _ := respond("complete", 2); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 confirming twice, got %d", w.Code)
+	}
+	if status := itemStatus(1); status != models.ItemStatusPending {
+		t.Errorf("Expected item 1 still pending, got %q", status)
+	}
+	if _, group := respond("complete", 1); group.Status != models.MatchStatusCompleted {
+		t.Errorf("Expected completed, got %q", group.Status)
+	}
+	for _, id := range []int{1, 3, 5} {
+		if status := itemStatus(id); status != models.ItemStatusTraded {
+			t.Errorf("Expected item %d traded, got %q", id, status)
+		}
+	}
+}
+
+func TestGroupMatch_DeclineEndsTrade(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	createRing(t)
+	if n, _ := testService.FindTradeCycles(); n != 1 {
+		t.Fatalf("Expected one ring trade, got %d", n)
+	}
+
+	router := makeAuthRouter(testHandler.DeclineGroupMatch, "/group-matches/:id/decline", "POST", 2)
+	w := performRequest(router, "POST", "/group-matches/1/decline", nil)
+	var group models.GroupMatch
+	json.Unmarshal(w.Body.Bytes(), &group)
+	if w.Code != http.StatusOK || group.Status != models.MatchStatusDeclined {
+		t.Fatalf("Expected declined, got %d %+v", w.Code, group)
+	}
+
+	accept := makeAuthRouter(testHandler.AcceptGroupMatch, "/group-matches/:id/accept", "POST", 1)
+	if w := performRequest(accept, "POST", "/group-matches/1/accept", nil); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 accepting a declined trade, got %d", w.Code)
+	}
+
+	// A declined ring is not offered again
+	if n, _ := testService.FindTradeCycles(); n != 0 {
+		t.Errorf("Expected no new proposals, got %d", n)
+	}
+}
+
+func TestGroupMatch_CancelFreesItems(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	createRing(t)
+	if n, _ := testService.FindTradeCycles(); n != 1 {
+		t.Fatalf("Expected one ring trade, got %d", n)
+	}
+
+	post := func(handler gin.HandlerFunc, action string, userID int) *httptest.ResponseRecorder {
+		router := makeAuthRouter(handler, "/group-matches/:id/"+action, "POST", userID)
+		return performRequest(router, "POST", "/group-matches/1/"+action, nil)
+	}
+
+	if w := post(testHandler.CancelGroupMatch, "cancel", 1); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 cancelling a proposed trade, got %d", w.Code)
+	}
+	for _, id := range []int{1, 2, 3} {
+		post(testHandler.AcceptGroupMatch, "accept", id)
+	}
+	post(testHandler.CompleteGroupMatch, "complete", 2)
+
+	// Charlie never confirms, so Bob calls it off
+	if w := post(testHandler.CancelGroupMatch, "cancel", 99); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for an outsider, got %d", w.Code)
+	}
+	w := post(testHandler.CancelGroupMatch, "cancel", 2)
+	var group models.GroupMatch
+	json.Unmarshal(w.Body.Bytes(), &group)
+	if w.Code != http.StatusOK || group.Status != models.MatchStatusCancelled {
+		t.Fatalf("Expected cancelled, got %d %+v", w.Code, group)
+	}
+	for _, id := range []int{1, 3, 5} {
+		if status := itemStatus(id); status != models.ItemStatusAvailable {
+			t.Errorf("Expected item %d available again, got %q", id, status)
+		}
+	}
+
+	if w := post(testHandler.CompleteGroupMatch, "complete", 1); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 confirming a cancelled trade, got %d", w.Code)
+	}
+}
+
+func TestUnmatch_ArchivesForBothSides(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	item1ID, _, matchID := createMatch(t)
+	path := "/matches/" + strconv.Itoa(matchID)
+
+	acceptRouter := makeAuthRouter(testHandler.AcceptMatch, "/matches/:match_id/accept", "POST", 1)
+	performRequest(acceptRouter, "POST", path+"/accept", nil)
+	commentRouter := makeAuthRouter(testHandler.CreateComment, "/comments", "POST", 2)
+	performRequest(commentRouter, "POST", "/comments", []byte(`{"match_id": `+strconv.Itoa(matchID)+`, "content": "See you Friday"}`))
+
+	unmatchRouter := makeAuthRouter(testHandler.Unmatch, "/matches/:match_id/unmatch", "POST", 3)
+	if w := performRequest(unmatchRouter, "POST", path+"/unmatch", nil); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for outsider, got %d", w.Code)
+	}
+	unmatchRouter = makeAuthRouter(testHandler.Unmatch, "/matches/:match_id/unmatch", "POST", 1)
+	if w := performRequest(unmatchRouter, "POST", path+"/unmatch", nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// The accepted trade is called off and its items are back on offer
+	if itemStatus(item1ID) != models.ItemStatusAvailable {
+		t.Errorf("Expected item available after unmatch, got %s", itemStatus(item1ID))
+	}
+
+	for _, userID := range []int{1, 2} {
+		router := makeAuthRouter(testHandler.GetMatches, "/matches", "GET", userID)
+		w := performRequest(router, "GET", "/matches", nil)
+		var page models.Page[models.MatchResponse]
+		json.Unmarshal(w.Body.Bytes(), &page)
+		if len(page.Data) != 0 {
+			t.Errorf("Expected no matches for user %d, got %+v", userID, page.Data)
+		}
+	}
+
+	commentsRouter := makeAuthRouter(testHandler.GetComments, "/matches/:match_id/comments", "GET", 2)
+	w := performRequest(commentsRouter, "GET", path+"/comments", nil)
+	var comments models.Page[models.Comment]
+	json.Unmarshal(w.Body.Bytes(), &comments)
+	if len(comments.Data) != 0 {
+		t.Errorf("Expected comments to be archived, got %+v", comments.Data)
+	}
+	w = performRequest(commentRouter, "POST", "/comments", []byte(`{"match_id": `+strconv.Itoa(matchID)+`, "content": "Hello?"}`))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 commenting on an archived match, got %d", w.Code)
+	}
+}
+
+func TestBlock_HidesUserAndStopsMatches(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	_, _, matchID := createMatch(t)
+
+	blockRouter := makeAuthRouter(testHandler.BlockUser, "/blocks", "POST", 1)
+	if w := performRequest(blockRouter, "POST", "/blocks", []byte(`{"user_id": 1}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 blocking yourself, got %d", w.Code)
+	}
+	if w := performRequest(blockRouter, "POST", "/blocks", []byte(`{"user_id": 2}`)); w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// Bob's items are gone from Alice's deck, but Alice's stay in Bob's
+	feedOwners := func(userID int) map[int]bool {
+		router := makeAuthRouter(testHandler.GetItems, "/items", "GET", userID)
+		w := performRequest(router, "GET", "/items?exclude_own=true&limit=100", nil)
+		var page models.Page[models.ItemWithOwner]
+		json.Unmarshal(w.Body.Bytes(), &page)
+		owners := map[int]bool{}
+		for _, item := range page.Data {
+			owners[item.UserID] = true
+		}
+		return owners
+	}
+	if feedOwners(1)[2] {
+		t.Error("Expected Bob's items to be hidden from Alice")
+	}
+	if !feedOwners(2)[1] {
+		t.Error("Expected the block to be invisible to Bob")
+	}
+
+	// Their existing match is archived and Bob's comments are refused
+	commentRouter := makeAuthRouter(testHandler.CreateComment, "/comments", "POST", 2)
+	w := performRequest(commentRouter, "POST", "/comments", []byte(`{"match_id": `+strconv.Itoa(matchID)+`, "content": "Hi"}`))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a blocked comment, got %d", w.Code)
+	}
+
+	// Mutual right swipes no longer make a match
+	result1, _ := testDB.Exec("INSERT INTO items (user_id, title) VALUES (?, ?)", 1, "Alice's Drill")
+	drillID, _ := result1.LastInsertId()
+	result2, _ := testDB.Exec("INSERT INTO items (user_id, title) VALUES (?, ?)", 2, "Bob's Saw")
+	sawID, _ := result2.LastInsertId()
+	swipeRight(t, 2, int(drillID))
+	swipeRight(t, 1, int(sawID))
+	var count int
+	testDB.QueryRow("SELECT COUNT(*) FROM matches WHERE archived_at IS NULL").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected no new match between blocked users, got %d", count)
+	}
+
+	// Only the blocker sees the block
+	listRouter := makeAuthRouter(testHandler.GetBlocks, "/blocks", "GET", 2)
+	w = performRequest(listRouter, "GET", "/blocks", nil)
+	var blocks []models.Block
+	json.Unmarshal(w.Body.Bytes(), &blocks)
+	if len(blocks) != 0 {
+		t.Errorf("Expected Bob to see no blocks, got %+v", blocks)
+	}
+
+	unblockRouter := makeAuthRouter(testHandler.UnblockUser, "/blocks/:user_id", "DELETE", 1)
+	if w := performRequest(unblockRouter, "DELETE", "/blocks/2", nil); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 on unblock, got %d", w.Code)
+	}
+	if w := performRequest(unblockRouter, "DELETE", "/blocks/2", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 unblocking twice, got %d", w.Code)
+	}
+	if !feedOwners(1)[2] {
+		t.Error("Expected Bob's items back in Alice's deck after unblocking")
+	}
+}
+
+func TestUndoSwipe(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	undo := func(userID int) *httptest.ResponseRecorder {
+		router := makeAuthRouter(testHandler.UndoSwipe, "/swipes/undo", "POST", userID)
+		return performRequest(router, "POST", "/swipes/undo", nil)
+	}
+
+	if w := undo(1); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 with nothing to undo, got %d", w.Code)
+	}
+
+	// Undoing the swipe that made a match removes the match too
+	_, item2ID, _ := createMatch(t)
+	w := undo(1)
+	var swipe models.Swipe
+	json.Unmarshal(w.Body.Bytes(), &swipe)
+	if w.Code != http.StatusOK || swipe.ItemID != item2ID {
+		t.Fatalf("Expected Alice's swipe on item %d undone, got %d. Body: %s", item2ID, w.Code, w.Body.String())
+	}
+	var count int
+	testDB.QueryRow("SELECT COUNT(*) FROM matches").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected the match to be rolled back, got %d", count)
+	}
+
+	// The item can be swiped on again
+	swipeRight(t, 1, item2ID)
+	testDB.QueryRow("SELECT COUNT(*) FROM matches").Scan(&count)
+	if count != 1 {
+		t.Fatalf("Expected the match to be recreated, got %d", count)
+	}
+
+	// Once someone has commented, the match and the swipe stand
+	var matchID int
+	testDB.QueryRow("SELECT id FROM matches").Scan(&matchID)
+	commentRouter := makeAuthRouter(testHandler.CreateComment, "/comments", "POST", 2)
+	performRequest(commentRouter, "POST", "/comments", []byte(`{"match_id": `+strconv.Itoa(matchID)+`, "content": "Deal?"}`))
+	if w := undo(1); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 undoing a discussed match, got %d", w.Code)
+	}
+
+	// Swipes outside the window are final
+	swipeRight(t, 3, 1)
+	testDB.Exec("UPDATE swipes SET created_at = datetime('now', '-1 minute') WHERE user_id = 3")
+	if w := undo(3); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for an old swipe, got %d", w.Code)
+	}
+}
+
+func TestSwipe_RepeatsAndIdempotencyKeys(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	router := makeAuthRouter(testHandler.CreateSwipe, "/swipes", "POST", 1)
+	swipe := func(body, key string) (*httptest.ResponseRecorder, models.Swipe) {
+		req, _ := http.NewRequest("POST", "/swipes", bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var s models.Swipe
+		json.Unmarshal(w.Body.Bytes(), &s)
+		return w, s
+	}
+
+	w, first := swipe(`{"item_id": 3, "direction": "right"}`, "key-1")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// Replays and plain repeats return the stored swipe
+	if w, again := swipe(`{"item_id": 3, "direction": "right"}`, "key-1"); w.Code != http.StatusOK || again.ID != first.ID {
+		t.Errorf("Expected 200 with swipe %d on replay, got %d %+v", first.ID, w.Code, again)
+	}
+	if w, again := swipe(`{"item_id": 3, "direction": "right"}`, ""); w.Code != http.StatusOK || again.ID != first.ID {
+		t.Errorf("Expected 200 with swipe %d on repeat, got %d %+v", first.ID, w.Code, again)
+	}
+	if w, _ := swipe(`{"item_id": 4, "direction": "right"}`, "key-1"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 reusing a key for another item, got %d", w.Code)
+	}
+
+	// Changing direction is a conflict that returns the existing swipe
+	w, _ = swipe(`{"item_id": 3, "direction": "left"}`, "")
+	var conflict struct {
+		Swipe models.Swipe `json:"swipe"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &conflict)
+	if w.Code != http.StatusConflict || conflict.Swipe.Direction != "right" {
+		t.Errorf("Expected 409 with the right swipe, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// A left-swiped item comes back once the cooldown has passed
+	swipe(`{"item_id": 5, "direction": "left"}`, "")
+	inDeck := func() bool {
+		itemsRouter := makeAuthRouter(testHandler.GetItems, "/items", "GET", 1)
+		w := performRequest(itemsRouter, "GET", "/items?exclude_own=true", nil)
+		var page models.Page[models.ItemWithOwner]
+		json.Unmarshal(w.Body.Bytes(), &page)
+		for _, item := range page.Data {
+			if item.ID == 5 {
+				return true
+			}
+		}
+		return false
+	}
+	if inDeck() {
+		t.Error("Expected the left-swiped item to leave the deck")
+	}
+	if w, _ := swipe(`{"item_id": 5, "direction": "right"}`, ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 re-swiping during the cooldown, got %d", w.Code)
+	}
+
+	testDB.Exec("UPDATE swipes SET created_at = datetime('now', '-31 days') WHERE item_id = 5")
+	if !inDeck() {
+		t.Error("Expected the left-swiped item to resurface after the cooldown")
+	}
+	if w, again := swipe(`{"item_id": 5, "direction": "right"}`, ""); w.Code != http.StatusCreated || again.Direction != "right" {
+		t.Errorf("Expected 201 re-swiping after the cooldown, got %d %+v", w.Code, again)
+	}
+}
+
+func TestSwipeBatch(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	// Bob already likes Alice's lamp, and the guitar is off the market
+	swipeRight(t, 2, 1)
+	testDB.Exec("UPDATE items SET status = ? WHERE id = 4", models.ItemStatusWithdrawn)
+
+	router := makeAuthRouter(testHandler.CreateSwipeBatch, "/swipes/batch", "POST", 1)
+	offline := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	at := func(seconds int) string {
+		return offline.Add(time.Duration(seconds) * time.Second).Format(time.RFC3339)
+	}
+	body := []byte(`{"swipes": [
+		{"item_id": 5, "direction": "left", "swiped_at": "` + at(2) + `"},
+		{"item_id": 3, "direction": "right", "swiped_at": "` + at(1) + `"},
+		{"item_id": 3, "direction": "right", "swiped_at": "` + at(3) + `"},
+		{"item_id": 4, "direction": "right"},
+		{"item_id": 2, "direction": "right"},
+		{"item_id": 6, "direction": "up"},
+		{"item_id": 999, "direction": "left"},
+		{"item_id": 6, "direction": "super"}
+	]}`)
+	w := performRequest(router, "POST", "/swipes/batch", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Results []models.SwipeResult `json:"results"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	want := []string{
+		models.SwipeResultCreated, models.SwipeResultCreated, models.SwipeResultDuplicate,
+		models.SwipeResultItemGone, models.SwipeResultOwnItem, models.SwipeResultInvalid, models.SwipeResultItemGone,
+		models.SwipeResultSuperUnsupported,
+	}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("Expected %d results, got %+v", len(want), resp.Results)
+	}
+	for i, status := range want {
+		if resp.Results[i].Status != status {
+			t.Errorf("Result %d: expected %s, got %s", i, status, resp.Results[i].Status)
+		}
+	}
+	if swiped := resp.Results[1].Swipe; swiped == nil || !swiped.CreatedAt.Equal(offline.Add(time.Second)) {
+		t.Errorf("Expected the client timestamp to be kept, got %+v", swiped)
+	}
+
+	// Matches are found once the batch is in
+	var count int
+	testDB.QueryRow("SELECT COUNT(*) FROM matches WHERE item1_id = 1 AND item2_id = 3").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected a match between the lamp and the cookbook, got %d", count)
+	}
+
+	// Replaying the batch changes nothing
+	w = performRequest(router, "POST", "/swipes/batch", body)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Results[0].Status != models.SwipeResultDuplicate || resp.Results[1].Status != models.SwipeResultDuplicate {
+		t.Errorf("Expected duplicates on replay, got %+v", resp.Results)
+	}
+
+	// Swipes dated before the offline window are dated on arrival instead
+	stale := time.Now().Add(-service.MaxOfflineSwipeAge - time.Hour).UTC().Format(time.RFC3339)
+	w = performRequest(router, "POST", "/swipes/batch", []byte(`{"swipes": [{"item_id": 6, "direction": "left", "swiped_at": "`+stale+`"}]}`))
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if swiped := resp.Results[0].Swipe; swiped == nil || time.Since(swiped.CreatedAt) > time.Minute {
+		t.Errorf("Expected a stale timestamp to be replaced, got %+v", swiped)
+	}
+
+	if w := performRequest(router, "POST", "/swipes/batch", []byte(`{"swipes": []}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an empty batch, got %d", w.Code)
+	}
+}
+
+func TestSuperLike(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	// Newer items from Charlie would otherwise come first in Alice's deck
+	testDB.Exec("INSERT INTO items (user_id, title) VALUES (?, ?)", 3, "Charlie's Newest")
+
+	swipe := func(userID, itemID int) *httptest.ResponseRecorder {
+		router := makeAuthRouter(testHandler.CreateSwipe, "/swipes", "POST", userID)
+		return performRequest(router, "POST", "/swipes", []byte(`{"item_id": `+strconv.Itoa(itemID)+`, "direction": "super"}`))
+	}
+
+	if w := swipe(2, 1); w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// Alice hears about it straight away
+	router := makeAuthRouter(testHandler.GetNotifications, "/notifications", "GET", 1)
+	w := performRequest(router, "GET", "/notifications", nil)
+	var notifications models.Page[models.Notification]
+	json.Unmarshal(w.Body.Bytes(), &notifications)
+	if len(notifications.Data) != 1 || notifications.Data[0].Type != models.NotificationSuperLike ||
+		notifications.Data[0].ActorID != 2 || notifications.Data[0].ItemID != 1 {
+		t.Fatalf("Expected a super like notification, got %+v", notifications.Data)
+	}
+
+	// and Bob's items are at the top of her deck
+	router = makeAuthRouter(testHandler.GetItems, "/items", "GET", 1)
+	w = performRequest(router, "GET", "/items?exclude_own=true", nil)
+	var page models.Page[models.ItemWithOwner]
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if len(page.Data) < 2 || page.Data[0].UserID != 2 || page.Data[1].UserID != 2 || !page.Data[0].OwnerSuperLiked {
+		t.Errorf("Expected Bob's items first, got %+v", page.Data)
+	}
+
+	// A super like counts as a right swipe for matching
+	swipeRight(t, 1, 3)
+	var count int
+	testDB.QueryRow("SELECT COUNT(*) FROM matches").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected a match, got %d", count)
+	}
+
+	// Nobody hears about super likes across a block, including earlier ones
+	notificationCount := func(userID int) int {
+		router := makeAuthRouter(testHandler.GetNotifications, "/notifications", "GET", userID)
+		var page models.Page[models.Notification]
+		json.Unmarshal(performRequest(router, "GET", "/notifications", nil).Body.Bytes(), &page)
+		return len(page.Data)
+	}
+	testDB.Exec("INSERT INTO blocks (blocker_id, blocked_id) VALUES (3, 2), (1, 2)")
+
+	// Three a day
+	if w := swipe(2, 5); w.Code != http.StatusCreated {
+		t.Errorf("Expected 201 for the second super like, got %d", w.Code)
+	}
+	testDB.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = 3").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected no notification for Charlie, who blocked Bob, got %d", count)
+	}
+	if n := notificationCount(1); n != 0 {
+		t.Errorf("Expected Alice's notification from Bob hidden once she blocked him, got %d", n)
+	}
+	testDB.Exec("DELETE FROM blocks")
+	if w := swipe(2, 2); w.Code != http.StatusCreated {
+		t.Errorf("Expected 201 for the third super like, got %d", w.Code)
+	}
+	if w := swipe(2, 6); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 past the quota, got %d", w.Code)
+	}
+	if w := swipe(2, 3); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 super liking your own item, got %d", w.Code)
+	}
+
+	// Undoing a super like refunds it and takes back the notification
+	undo := makeAuthRouter(testHandler.UndoSwipe, "/swipes/undo", "POST", 2)
+	if w := performRequest(undo, "POST", "/swipes/undo", nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 undoing the super like, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if n := notificationCount(1); n != 1 {
+		t.Errorf("Expected only the first super like notification left for Alice, got %d", n)
+	}
+	if w := swipe(2, 6); w.Code != http.StatusCreated {
+		t.Errorf("Expected 201 with the refunded super like, got %d", w.Code)
+	}
+}
+
+func TestDeck_Ranking(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	// Alice has liked a book before, and Charlie likes her lamp
+	result, _ := testDB.Exec(`INSERT INTO items (user_id, title, category_id)
+		SELECT 3, 'Charlie''s Novel', category_id FROM items WHERE id = 3`)
+	novelID, _ := result.LastInsertId()
+	swipeRight(t, 1, int(novelID))
+	swipeRight(t, 3, 1)
+
+	getDeck := func(h *handlers.Handler) models.Deck {
+		router := makeAuthRouter(h.GetDeck, "/deck", "GET", 1)
+		w := performRequest(router, "GET", "/deck", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+		}
+		var deck models.Deck
+		json.Unmarshal(w.Body.Bytes(), &deck)
+		return deck
+	}
+
+	deck := getDeck(testHandler)
+	if deck.Ranker != "weighted" || len(deck.Data) != 4 {
+		t.Fatalf("Expected 4 cards from the weighted ranker, got %s %+v", deck.Ranker, deck.Data)
+	}
+	// Bob's cookbook matches Alice's taste, then Charlie is interested in her
+	if deck.Data[0].ID != 3 {
+		t.Errorf("Expected the cookbook first, got %d", deck.Data[0].ID)
+	}
+	if deck.Data[1].UserID != 3 || deck.Data[2].UserID != 3 || deck.Data[3].ID != 4 {
+		t.Errorf("Expected Charlie's items next and the guitar last, got %+v", deck.Data)
+	}
+
+	// Another strategy can be swapped in
+	newest := handlers.New(service.New(store.New(testDB.DB), service.WithDeckExperiment(ranking.Experiment{
+		Name:     "deck",
+		Variants: []ranking.Variant{{Ranker: ranking.Newest{}, Weight: 1}},
+	})))
+	testDB.Exec("UPDATE items SET created_at = datetime('now', '+1 minute') WHERE id = 4")
+	if deck := getDeck(newest); deck.Ranker != "newest" || deck.Data[0].ID != 4 {
+		t.Errorf("Expected the newest ranker to put the guitar first, got %s %+v", deck.Ranker, deck.Data)
+	}
+
+	// A super liker's items lead the deck, even when they are too old to be
+	// among the ranked candidates
+	for i := 0; i < service.MaxDeckCandidates; i++ {
+		testDB.Exec("INSERT INTO items (user_id, title, created_at) VALUES (2, 'Filler', datetime('now', '+1 hour'))")
+	}
+	superRouter := makeAuthRouter(testHandler.CreateSwipe, "/swipes", "POST", 3)
+	if w := performRequest(superRouter, "POST", "/swipes", []byte(`{"item_id": 2, "direction": "super"}`)); w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	deck = getDeck(testHandler)
+	if len(deck.Data) < 2 || deck.Data[0].UserID != 3 || !deck.Data[0].OwnerSuperLiked || deck.Data[1].UserID != 3 {
+		t.Errorf("Expected Charlie's items first, got %+v", deck.Data[:2])
+	}
+	seen := map[int]bool{}
+	for _, item := range deck.Data {
+		if seen[item.ID] {
+			t.Errorf("Expected item %d in the deck once", item.ID)
+		}
+		seen[item.ID] = true
+	}
+}
+
+func TestRankingExperiment_Assign(t *testing.T) {
+	e := ranking.Experiment{Name: "deck", Variants: []ranking.Variant{
+		{Ranker: ranking.DefaultWeighted, Weight: 1},
+		{Ranker: ranking.Newest{}, Weight: 1},
+	}}
+
+	counts := map[string]int{}
+	for userID := 1; userID <= 1000; userID++ {
+		r := e.Assign(userID)
+		if r.Name() != e.Assign(userID).Name() {
+			t.Fatalf("Expected user %d to keep their variant", userID)
+		}
+		counts[r.Name()]++
+	}
+	if counts["weighted"] < 400 || counts["newest"] < 400 {
+		t.Errorf("Expected roughly even split, got %v", counts)
+	}
+
+	if (ranking.Experiment{Name: "empty"}).Assign(1) != nil {
+		t.Error("Expected no ranker from an empty experiment")
+	}
+}
+
+func TestSimilarItems(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	// Alice and Bob both like the board games and an old puzzle of Charlie's
+	result, _ := testDB.Exec("INSERT INTO items (user_id, title, created_at) VALUES (3, 'Old Puzzle', datetime('now', '-60 days'))")
+	puzzleID, _ := result.LastInsertId()
+	for _, userID := range []int{1, 2} {
+		swipeRight(t, userID, 5)
+		swipeRight(t, userID, int(puzzleID))
+	}
+	// A single shared like is not enough to call two items similar
+	swipeRight(t, 1, 3)
+
+	n, err := testService.BuildItemSimilarities()
+	if err != nil || n != 2 {
+		t.Fatalf("Expected neighbours for 2 items, got %d (%v)", n, err)
+	}
+
+	router := makeAuthRouter(testHandler.GetSimilarItems, "/items/:id/similar", "GET", 2)
+	w := performRequest(router, "GET", "/items/5/similar", nil)
+	var similar []models.ItemWithOwner
+	json.Unmarshal(w.Body.Bytes(), &similar)
+	if w.Code != http.StatusOK || len(similar) != 1 || similar[0].ID != int(puzzleID) || similar[0].Similarity != 1 {
+		t.Fatalf("Expected the puzzle with similarity 1, got %d %+v", w.Code, similar)
+	}
+
+	// Owners are not recommended their own items
+	router = makeAuthRouter(testHandler.GetSimilarItems, "/items/:id/similar", "GET", 3)
+	w = performRequest(router, "GET", "/items/5/similar", nil)
+	json.Unmarshal(w.Body.Bytes(), &similar)
+	if len(similar) != 0 {
+		t.Errorf("Expected nothing for the owner, got %+v", similar)
+	}
+
+	if w := performRequest(router, "GET", "/items/999/similar", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing item, got %d", w.Code)
+	}
+
+	// Dana likes the board games, so the old puzzle jumps to the top of her deck
+	testDB.Exec(`INSERT INTO users (name, email, password_hash, email_verified_at)
+		VALUES ('Dana', 'dana@example.com', 'x', CURRENT_TIMESTAMP)`)
+	swipeRight(t, 4, 5)
+	router = makeAuthRouter(testHandler.GetDeck, "/deck", "GET", 4)
+	w = performRequest(router, "GET", "/deck", nil)
+	var deck models.Deck
+	json.Unmarshal(w.Body.Bytes(), &deck)
+	if len(deck.Data) == 0 || deck.Data[0].ID != int(puzzleID) {
+		t.Errorf("Expected the puzzle first in Dana's deck, got %+v", deck.Data)
+	}
+}
+
+func TestInterestHints(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	// Newer items from Charlie would otherwise come first in Alice's feed
+	testDB.Exec("INSERT INTO items (user_id, title) VALUES (?, ?)", 3, "Charlie's Newest")
+
+	getFeed := func() []models.ItemWithOwner {
+		router := makeAuthRouter(testHandler.GetItems, "/items", "GET", 1)
+		w := performRequest(router, "GET", "/items?exclude_own=true", nil)
+		var page models.Page[models.ItemWithOwner]
+		json.Unmarshal(w.Body.Bytes(), &page)
+		return page.Data
+	}
+	hinted := func(items []models.ItemWithOwner) int {
+		n := 0
+		for _, item := range items {
+			if item.InterestHint {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Bob likes Alice's item, but she has not opted in
+	swipeRight(t, 2, 1)
+	if items := getFeed(); hinted(items) != 0 || items[0].UserID == 2 {
+		t.Fatalf("Expected no hints before opting in, got %+v", items)
+	}
+
+	router := makeAuthRouter(testHandler.SetInterestHints, "/me/interest-hints", "PUT", 1)
+	if w := performRequest(router, "PUT", "/me/interest-hints", []byte(`{}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without enabled, got %d", w.Code)
+	}
+	w := performRequest(router, "PUT", "/me/interest-hints", []byte(`{"enabled": true}`))
+	var user models.User
+	json.Unmarshal(w.Body.Bytes(), &user)
+	if w.Code != http.StatusOK || !user.InterestHints {
+		t.Fatalf("Expected interest hints on, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// Bob's items come first, without saying they are his
+	items := getFeed()
+	if len(items) < 2 || !items[0].InterestHint || !items[1].InterestHint || hinted(items) != 2 {
+		t.Fatalf("Expected Bob's two items first as hints, got %+v", items)
+	}
+	for _, item := range items {
+		if item.UserID == 2 || item.OwnerName == "Bob" {
+			t.Errorf("Expected Bob to stay anonymous, got %+v", item)
+		}
+	}
+
+	// He stays anonymous in search results and in his item's edit history
+	router = makeAuthRouter(testHandler.SearchItems, "/items/search", "GET", 1)
+	w = performRequest(router, "GET", "/items/search?q=cookbook", nil)
+	var results []models.ItemSearchResult
+	json.Unmarshal(w.Body.Bytes(), &results)
+	if len(results) != 1 || !results[0].InterestHint || results[0].UserID != 0 || results[0].OwnerName != "" {
+		t.Errorf("Expected the cookbook found as an anonymous hint, got %+v", results)
+	}
+
+	patch := makeAuthRouter(testHandler.UpdateItem, "/items/:id", "PATCH", 2)
+	performRequest(patch, "PATCH", "/items/3", []byte(`{"description": "Now with desserts"}`))
+	router = makeAuthRouter(testHandler.GetItemRevisions, "/items/:id/revisions", "GET", 1)
+	var history models.ItemHistory
+	json.Unmarshal(performRequest(router, "GET", "/items/3/revisions", nil).Body.Bytes(), &history)
+	if len(history.Revisions) != 1 || history.Revisions[0].UserID != 0 {
+		t.Errorf("Expected one revision without its editor, got %+v", history.Revisions)
+	}
+
+	// Liking back makes the match and ends the hint
+	swipeRight(t, 1, 3)
+	var count int
+	testDB.QueryRow("SELECT COUNT(*) FROM matches").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected a match, got %d", count)
+	}
+	if items := getFeed(); hinted(items) != 0 {
+		t.Errorf("Expected no hints once the like is mutual, got %+v", items)
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	router := gin.New()
+	router.POST("/auth/register", testHandler.Register)
+	router.POST("/auth/refresh", testHandler.Refresh)
+	router.POST("/auth/logout", testHandler.Logout)
+
+	w := performRequest(router, "POST", "/auth/register",
+		[]byte(`{"name": "Dana", "email": "dana@example.com", "password": "password123"}`))
+	var auth models.AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &auth)
+	if w.Code != http.StatusCreated || auth.Token == "" || auth.RefreshToken == "" || auth.ExpiresIn != 900 {
+		t.Fatalf("Expected tokens on register, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	refresh := func(token string) (*httptest.ResponseRecorder, models.AuthResponse) {
+		w := performRequest(router, "POST", "/auth/refresh", []byte(`{"refresh_token": "`+token+`"}`))
+		var resp models.AuthResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	// Refreshing rotates the refresh token
+	w, rotated := refresh(auth.RefreshToken)
+	if w.Code != http.StatusOK || rotated.RefreshToken == "" || rotated.RefreshToken == auth.RefreshToken || rotated.User.Email != "dana@example.com" {
+		t.Fatalf("Expected a new refresh token, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// Reusing the old one revokes the whole family, including the new token
+	if w, _ := refresh(auth.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 reusing a refresh token, got %d", w.Code)
+	}
+	if w, _ := refresh(rotated.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the rotated token to be revoked after reuse, got %d", w.Code)
+	}
+	var stored string
+	testDB.QueryRow("SELECT token_hash FROM refresh_tokens LIMIT 1").Scan(&stored)
+	if stored == auth.RefreshToken {
+		t.Error("Expected refresh tokens to be stored hashed")
+	}
+
+	// Logging out ends a fresh session
+	w = performRequest(router, "POST", "/auth/register",
+		[]byte(`{"name": "Eve", "email": "eve@example.com", "password": "password123"}`))
+	json.Unmarshal(w.Body.Bytes(), &auth)
+	if w := performRequest(router, "POST", "/auth/logout", []byte(`{"refresh_token": "`+auth.RefreshToken+`"}`)); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 logging out, got %d", w.Code)
+	}
+	if w, _ := refresh(auth.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 after logout, got %d", w.Code)
+	}
+	if w, _ := refresh("not-a-token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unknown token, got %d", w.Code)
+	}
+}
+
+func TestSessions_ListAndRevoke(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	st := store.New(testDB.DB)
+	sessions := middleware.NewSessionCache(st, time.Minute)
+	h := handlers.New(service.New(st, service.WithSessionRevoked(sessions.Forget)))
+
+	router := gin.New()
+	router.POST("/auth/register", h.Register)
+	router.POST("/auth/login", h.Login)
+	router.POST("/auth/refresh", h.Refresh)
+	api := router.Group("/")
+	api.Use(middleware.AuthRequired(sessions))
+	api.GET("/me", h.GetMe)
+	api.GET("/sessions", h.GetSessions)
+	api.DELETE("/sessions", h.RevokeOtherSessions)
+	api.DELETE("/sessions/:session_id", h.RevokeSession)
+
+	signIn := func(path, body string) models.AuthResponse {
+		w := performRequest(router, "POST", path, []byte(body))
+		var auth models.AuthResponse
+		json.Unmarshal(w.Body.Bytes(), &auth)
+		if auth.Token == "" {
+			t.Fatalf("Expected tokens from %s, got %d. Body: %s", path, w.Code, w.Body.String())
+		}
+		return auth
+	}
+	as := func(auth models.AuthResponse, method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	laptop := signIn("/auth/register", `{"name": "Dana", "email": "dana@example.com", "password": "password123"}`)
+	phone := signIn("/auth/login", `{"email": "dana@example.com", "password": "password123"}`)
+
+	w := as(laptop, "GET", "/sessions")
+	var list []models.Session
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d. Body: %s", len(list), w.Body.String())
+	}
+	var phoneID string
+	for _, s := range list {
+		if !s.Current {
+			phoneID = s.ID
+		}
+	}
+
+	// The phone's session is cached as active before it is revoked
+	if w := as(phone, "GET", "/me"); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if w := as(laptop, "DELETE", "/sessions/"+phoneID); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 revoking a session, got %d", w.Code)
+	}
+	if w := as(phone, "GET", "/me"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the revoked session's access token to stop working, got %d", w.Code)
+	}
+	if w := performRequest(router, "POST", "/auth/refresh", []byte(`{"refresh_token": "`+phone.RefreshToken+`"}`)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the revoked session's refresh token to stop working, got %d", w.Code)
+	}
+	if w := as(laptop, "DELETE", "/sessions/"+phoneID); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 revoking it again, got %d", w.Code)
+	}
+
+	// Signing out everywhere else keeps the current session
+	tablet := signIn("/auth/login", `{"email": "dana@example.com", "password": "password123"}`)
+	w = as(laptop, "DELETE", "/sessions")
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte(`"revoked":1`)) {
+		t.Errorf("Expected one session revoked, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if w := as(tablet, "GET", "/me"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the tablet to be signed out, got %d", w.Code)
+	}
+	if w := as(laptop, "GET", "/me"); w.Code != http.StatusOK {
+		t.Errorf("Expected the current session to keep working, got %d", w.Code)
+	}
+}
+
+func TestKeyRing_RotationAndJWKS(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+	defer middleware.UseKeyRing(middleware.DevKeyRing())
+
+	_, private, _ := ed25519.GenerateKey(nil)
+	der, _ := x509.MarshalPKCS8PrivateKey(private)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "2025-06.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	oldKey := `{"kid": "2025-01", "alg": "HS256", "secret": "an-old-secret-that-is-long-enough-to-use"}`
+	writeRing := func(name, config string) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(config), 0600)
+		return path
+	}
+
+	before, err := middleware.LoadKeyRing(writeRing("before.json", `{"active": "2025-01", "keys": [`+oldKey+`]}`))
+	if err != nil {
+		t.Fatal("Failed to load key ring:", err)
+	}
+	rotated, err := middleware.LoadKeyRing(writeRing("rotated.json",
+		`{"active": "2025-06", "keys": [{"kid": "2025-06", "alg": "EdDSA", "key_file": "2025-06.pem"}, `+oldKey+`]}`))
+	if err != nil {
+		t.Fatal("Failed to load key ring:", err)
+	}
+	retired, err := middleware.LoadKeyRing(writeRing("retired.json",
+		`{"active": "2025-06", "keys": [{"kid": "2025-06", "alg": "EdDSA", "key_file": "2025-06.pem"}]}`))
+	if err != nil {
+		t.Fatal("Failed to load key ring:", err)
+	}
+
+	session, _ := testService.StartSession(1, "", "")
+	middleware.UseKeyRing(before)
+	oldToken, _ := middleware.GenerateToken(1, "alice@example.com", session.SessionID)
+	middleware.UseKeyRing(rotated)
+	newToken, _ := middleware.GenerateToken(1, "alice@example.com", session.SessionID)
+
+	router := gin.New()
+	router.GET("/.well-known/jwks.json", testHandler.GetJWKS)
+	api := router.Group("/")
+	api.Use(middleware.AuthRequired(middleware.NewSessionCache(store.New(testDB.DB), time.Minute)))
+	api.GET("/me", testHandler.GetMe)
+	me := func(token string) int {
+		req, _ := http.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Tokens signed before the rotation keep working while the old key is in the ring
+	if code := me(oldToken); code != http.StatusOK {
+		t.Errorf("Expected the old token to validate after rotation, got %d", code)
+	}
+	if code := me(newToken); code != http.StatusOK {
+		t.Errorf("Expected the new token to validate, got %d", code)
+	}
+	middleware.UseKeyRing(retired)
+	if code := me(oldToken); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 once the old key is retired, got %d", code)
+	}
+
+	// Only the public EdDSA key is published
+	w := performRequest(router, "GET", "/.well-known/jwks.json", nil)
+	var jwks middleware.JWKS
+	json.Unmarshal(w.Body.Bytes(), &jwks)
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "2025-06" || jwks.Keys[0].KeyType != "OKP" ||
+		jwks.Keys[0].X != base64.RawURLEncoding.EncodeToString(private.Public().(ed25519.PublicKey)) {
+		t.Errorf("Expected the Ed25519 public key, got %s", w.Body.String())
+	}
+
+	// Short HS256 secrets are refused wherever they come from
+	if _, err := middleware.NewHMACKey("short", []byte("too-short")); err == nil {
+		t.Error("Expected a short HS256 secret to be refused")
+	}
+	if _, err := middleware.LoadKeyRing(writeRing("short.json",
+		`{"active": "short", "keys": [{"kid": "short", "alg": "HS256", "secret": "too-short"}]}`)); err == nil {
+		t.Error("Expected a key ring with a short HS256 secret to be refused")
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	mailDir := t.TempDir()
+	mail, _ := mailer.NewLocal(mailDir)
+	h := handlers.New(service.New(store.New(testDB.DB), service.WithMailer(mail)))
+
+	router := gin.New()
+	router.POST("/auth/register", h.Register)
+	router.POST("/auth/login", h.Login)
+	router.POST("/auth/refresh", h.Refresh)
+	router.POST("/auth/forgot-password", h.ForgotPassword)
+	router.POST("/auth/reset-password", h.ResetPassword)
+
+	w := performRequest(router, "POST", "/auth/register",
+		[]byte(`{"name": "Dana", "email": "dana@example.com", "password": "password123"}`))
+	var auth models.AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &auth)
+
+	sentMail := func() []string {
+		entries, _ := os.ReadDir(mailDir)
+		var bodies []string
+		for _, e := range entries {
+			b, _ := os.ReadFile(filepath.Join(mailDir, e.Name()))
+			if strings.Contains(string(b), "Subject: Reset your Bartr password") {
+				bodies = append(bodies, string(b))
+			}
+		}
+		return bodies
+	}
+
+	// Unknown emails get the same answer, and no mail
+	if w := performRequest(router, "POST", "/auth/forgot-password", []byte(`{"email": "nobody@example.com"}`)); w.Code != http.StatusAccepted {
+		t.Errorf("Expected 202 for an unknown email, got %d", w.Code)
+	}
+	if w := performRequest(router, "POST", "/auth/forgot-password", []byte(`{"email": "dana@example.com"}`)); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", w.Code)
+	}
+	mails := sentMail()
+	if len(mails) != 1 || !strings.Contains(mails[0], "To: dana@example.com") {
+		t.Fatalf("Expected one reset email to Dana, got %q", mails)
+	}
+	// The token is the only word of its length in the email
+	tokenIn := func(mail string) string {
+		for _, field := range strings.Fields(mail) {
+			if len(field) == 43 {
+				return field
+			}
+		}
+		return ""
+	}
+	token := tokenIn(mails[0])
+
+	reset := func(token, password string) int {
+		return performRequest(router, "POST", "/auth/reset-password",
+			[]byte(`{"token": "`+token+`", "password": "`+password+`"}`)).Code
+	}
+	if code := reset("wrong-token", "newpassword"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a wrong token, got %d", code)
+	}
+	if code := reset(token, "newpassword"); code != http.StatusOK {
+		t.Fatalf("Expected 200 resetting the password, got %d", code)
+	}
+	if code := reset(token, "another-password"); code != http.StatusBadRequest {
+		t.Errorf("Expected the token to work only once, got %d", code)
+	}
+
+	// Existing sessions are signed out and only the new password works
+	if w := performRequest(router, "POST", "/auth/refresh", []byte(`{"refresh_token": "`+auth.RefreshToken+`"}`)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected old sessions to be revoked, got %d", w.Code)
+	}
+	if w := performRequest(router, "POST", "/auth/login", []byte(`{"email": "dana@example.com", "password": "password123"}`)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the old password to stop working, got %d", w.Code)
+	}
+	if w := performRequest(router, "POST", "/auth/login", []byte(`{"email": "dana@example.com", "password": "newpassword"}`)); w.Code != http.StatusOK {
+		t.Errorf("Expected to sign in with the new password, got %d", w.Code)
+	}
+
+	// Expired tokens are refused
+	expiring := handlers.New(service.New(store.New(testDB.DB), service.WithMailer(mail), service.WithPasswordResetTTL(-time.Minute)))
+	router.POST("/auth/forgot-password-expired", expiring.ForgotPassword)
+	performRequest(router, "POST", "/auth/forgot-password-expired", []byte(`{"email": "dana@example.com"}`))
+	mails = sentMail()
+	if code := reset(tokenIn(mails[len(mails)-1]), "newerpassword"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an expired token, got %d", code)
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	setupTest(t)
+	defer teardownTest()
+
+	mailDir := t.TempDir()
+	mail, _ := mailer.NewLocal(mailDir)
+	h := handlers.New(service.New(store.New(testDB.DB), service.WithMailer(mail)))
+
+	router := gin.New()
+	router.POST("/auth/register", h.Register)
+	router.POST("/auth/verify-email", h.VerifyEmail)
+	w := performRequest(router, "POST", "/auth/register",
+		[]byte(`{"name": "Dana", "email": "dana@example.com", "password": "password123"}`))
+	var auth models.AuthResponse
+	json.Unmarshal(w.Body.Bytes(), &auth)
+	if w.Code != http.StatusCreated || auth.Token == "" || auth.User.EmailVerifiedAt != nil {
+		t.Fatalf("Expected an unverified account with a token, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	dana := auth.User.ID
+
+	// The newest verification email holds the token
+	latestToken := func() string {
+		entries, _ := os.ReadDir(mailDir)
+		b, _ := os.ReadFile(filepath.Join(mailDir, entries[len(entries)-1].Name()))
+		for _, field := range strings.Fields(string(b)) {
+			if len(field) == 43 {
+				return field
+			}
+		}
+		return ""
+	}
+	firstToken := latestToken()
+
+	createItem := func() int {
+		router := makeAuthRouter(h.CreateItem, "/items", "POST", dana)
+		return performRequest(router, "POST", "/items", []byte(`{"title": "Dana's Bike"}`)).Code
+	}
+	swipe := func(itemID int, direction string) int {
+		router := makeAuthRouter(h.CreateSwipe, "/swipes", "POST", dana)
+		return performRequest(router, "POST", "/swipes",
+			[]byte(`{"item_id": `+strconv.Itoa(itemID)+`, "direction": "`+direction+`"}`)).Code
+	}
+
+	// Unverified users can browse and pass on items, but not list or like them
+	if code := createItem(); code != http.StatusForbidden {
+		t.Errorf("Expected 403 creating an item, got %d", code)
+	}
+	if code := swipe(1, "right"); code != http.StatusForbidden {
+		t.Errorf("Expected 403 swiping right, got %d", code)
+	}
+	if code := swipe(2, "left"); code != http.StatusCreated {
+		t.Errorf("Expected 201 swiping left, got %d", code)
+	}
+	batchRouter := makeAuthRouter(h.CreateSwipeBatch, "/swipes/batch", "POST", dana)
+	w = performRequest(batchRouter, "POST", "/swipes/batch", []byte(`{"swipes": [{"item_id": 3, "direction": "right"}]}`))
+	var batch struct {
+		Results []models.SwipeResult `json:"results"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &batch)
+	if len(batch.Results) != 1 || batch.Results[0].Status != models.SwipeResultUnverified {
+		t.Errorf("Expected the batched right swipe to be refused, got %s", w.Body.String())
+	}
+
+	// Asking again replaces the first token
+	resend := makeAuthRouter(h.ResendVerificationEmail, "/me/verification-email", "POST", dana)
+	if w := performRequest(resend, "POST", "/me/verification-email", nil); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202 resending, got %d", w.Code)
+	}
+	verify := func(token string) *httptest.ResponseRecorder {
+		return performRequest(router, "POST", "/auth/verify-email", []byte(`{"token": "`+token+`"}`))
+	}
+	if w := verify(firstToken); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected the replaced token to be refused, got %d", w.Code)
+	}
+	w = verify(latestToken())
+	var user models.User
+	json.Unmarshal(w.Body.Bytes(), &user)
+	if w.Code != http.StatusOK || user.EmailVerifiedAt == nil {
+		t.Fatalf("Expected the email to be verified, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	if code := createItem(); code != http.StatusCreated {
+		t.Errorf("Expected 201 creating an item once verified, got %d", code)
+	}
+	if code := swipe(1, "right"); code != http.StatusCreated {
+		t.Errorf("Expected 201 swiping right once verified, got %d", code)
+	}
+	if w := performRequest(resend, "POST", "/me/verification-email", nil); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 once verified, got %d", w.Code)
+	}
+}
@@ -0,0 +1,52 @@
+package media
+
+// gifFrameCount counts the frames of a GIF by walking its block structure,
+// without decompressing any of them. It reports false when the stream is
+// malformed or truncated.
+func gifFrameCount(data []byte) (int, bool) {
+	// Header and logical screen descriptor
+	if len(data) < 13 {
+		return 0, false
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	frames := 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // Extension: label, then data sub-blocks
+			i += 2
+		case 0x2C: // Image descriptor, optional local color table, LZW code size
+			if i+10 > len(data) {
+				return 0, false
+			}
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			i++
+			frames++
+		case 0x3B: // Trailer
+			return frames, true
+		default:
+			return 0, false
+		}
+
+		// Sub-blocks end with an empty one
+		for {
+			if i >= len(data) {
+				return 0, false
+			}
+			size := int(data[i])
+			i += 1 + size
+			if size == 0 {
+				break
+			}
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,125 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+// MaxUploadSize is the largest image file accepted, in bytes.
+const MaxUploadSize = 10 << 20
+
+// maxPixels guards against decompression bombs: small files that decode to
+// enormous bitmaps.
+const maxPixels = 40_000_000
+
+var (
+	ErrTooLarge        = errors.New("image is larger than 10MB")
+	ErrUnsupportedType = errors.New("only JPEG, PNG and GIF images are supported")
+	ErrInvalidImage    = errors.New("file is not a valid image")
+)
+
+// extensions maps the content types we accept to the extension used when storing them.
+var extensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// Image is an uploaded image after validation and metadata stripping.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+
+	// decoded is kept so variants can be rendered without decoding again
+	decoded image.Image
+}
+
+// Sanitize reads an uploaded image, checks its real type from its content
+// rather than any file name, and re-encodes it. Re-encoding drops every
+// metadata block (EXIF, GPS, XMP, comments); JPEG orientation is applied to
+// the pixels first so photos keep facing the right way.
+func Sanitize(r io.Reader) (*Image, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxUploadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxUploadSize {
+		return nil, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := extensions[contentType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrInvalidImage
+	}
+
+	var out bytes.Buffer
+	var decoded image.Image
+	width, height := cfg.Width, cfg.Height
+
+	switch contentType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		img = applyOrientation(img, jpegOrientation(data))
+		decoded = img
+		width, height = img.Bounds().Dx(), img.Bounds().Dy()
+		if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, err
+		}
+
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		decoded = img
+		if err := png.Encode(&out, img); err != nil {
+			return nil, err
+		}
+
+	case "image/gif":
+		// Every frame decodes to at most the logical screen size, so the
+		// frames are counted first and nothing is decoded past the limit
+		frames, ok := gifFrameCount(data)
+		if !ok || frames == 0 || frames*width*height > maxPixels {
+			return nil, ErrInvalidImage
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		decoded = g.Image[0]
+		if err := gif.EncodeAll(&out, g); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Image{
+		Data:        out.Bytes(),
+		ContentType: contentType,
+		Extension:   ext,
+		Width:       width,
+		Height:      height,
+		decoded:     decoded,
+	}, nil
+}
@@ -0,0 +1,167 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// Variant names, also used as the ?size= value when serving photos.
+const (
+	VariantThumb = "thumb"
+	VariantCard  = "card"
+)
+
+const (
+	// thumbSize is the edge of the square, center-cropped thumbnail.
+	thumbSize = 200
+	// cardSize bounds the longest edge of the swipe-card image.
+	cardSize = 800
+)
+
+// Variant is a resized rendition of an uploaded image.
+type Variant struct {
+	Name        string
+	Data        []byte
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+}
+
+// Variants renders the thumbnail and card sizes of a sanitized image. Images
+// with transparency are encoded as PNG, everything else as JPEG. Animated
+// GIFs use their first frame.
+func Variants(img *Image) ([]Variant, error) {
+	src := img.decoded
+	if src == nil {
+		decoded, _, err := image.Decode(bytes.NewReader(img.Data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		src = decoded
+	}
+
+	thumb := resize(cropSquare(src), thumbSize, thumbSize)
+
+	b := src.Bounds()
+	cw, ch := fitWithin(b.Dx(), b.Dy(), cardSize)
+	card := resize(src, cw, ch)
+
+	variants := make([]Variant, 0, 2)
+	for _, v := range []struct {
+		name string
+		img  *image.RGBA
+	}{{VariantThumb, thumb}, {VariantCard, card}} {
+		encoded, err := encodeVariant(v.name, v.img)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, *encoded)
+	}
+
+	return variants, nil
+}
+
+func encodeVariant(name string, img *image.RGBA) (*Variant, error) {
+	var buf bytes.Buffer
+	v := &Variant{Name: name, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+
+	if img.Opaque() {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+		v.ContentType, v.Extension = "image/jpeg", "jpg"
+	} else {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		v.ContentType, v.Extension = "image/png", "png"
+	}
+
+	v.Data = buf.Bytes()
+	return v, nil
+}
+
+// fitWithin scales w x h down so neither edge exceeds max. Images that already
+// fit are left at their original size.
+func fitWithin(w, h, max int) (int, int) {
+	if w <= max && h <= max {
+		return w, h
+	}
+	if w >= h {
+		return max, maxInt(1, h*max/w)
+	}
+	return maxInt(1, w*max/h), max
+}
+
+// cropSquare returns the centered square region of img.
+func cropSquare(img image.Image) image.Image {
+	b := img.Bounds()
+	size := b.Dx()
+	if b.Dy() < size {
+		size = b.Dy()
+	}
+
+	x := b.Min.X + (b.Dx()-size)/2
+	y := b.Min.Y + (b.Dy()-size)/2
+	rect := image.Rect(x, y, x+size, y+size)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+// resize scales img to w x h. Each destination pixel is the average of the
+// source pixels it covers (a box filter), which gives clean downscales
+// without any dependency outside the standard library. When upscaling, a
+// destination pixel covers less than one source pixel and this degrades to
+// nearest-neighbour sampling.
+func resize(img image.Image, w, h int) *image.RGBA {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	sw, sh := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		y0 := y * sh / h
+		y1 := maxInt(y0+1, (y+1)*sh/h)
+
+		for x := 0; x < w; x++ {
+			x0 := x * sw / w
+			x1 := maxInt(x0+1, (x+1)*sw/w)
+
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					bl += uint32(p[2])
+					a += uint32(p[3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(bl / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -9,30 +12,42 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// secret key
-var jwtSecret = []byte("your-secret-key-change-this-in-production")
+// AccessTokenTTL is how long an access token is valid. Clients renew it
+// with a refresh token.
+const AccessTokenTTL = 15 * time.Minute
 
 type Claims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    int    `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID int, email string) (string, error) {
+// GenerateToken issues an access token for a session. Each token gets its
+// own random jti.
+func GenerateToken(userID int, email, sessionID string) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 7 * time.Hour)), // 7 days
+			ID:        hex.EncodeToString(jti),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return keyRing.Load().signToken(claims)
 }
 
-func AuthRequired() gin.HandlerFunc {
+// AuthRequired accepts requests with a valid access token whose session has
+// not been revoked.
+func AuthRequired(sessions *SessionCache) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -49,9 +64,7 @@ func AuthRequired() gin.HandlerFunc {
 		}
 
 		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
+		token, err := jwt.ParseWithClaims(tokenString, claims, keyRing.Load().verifyKey)
 
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token", "details": err.Error()})
@@ -59,14 +72,28 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
-		if !token.Valid {
+		if !token.Valid || claims.SessionID == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is not valid"})
 			c.Abort()
 			return
 		}
 
+		active, err := sessions.Active(claims.SessionID, claims.UserID)
+		if err != nil {
+			log.Printf("Error checking session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check session"})
+			c.Abort()
+			return
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set("userID", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("sessionID", claims.SessionID)
 		c.Next()
 	}
 }
@@ -77,4 +104,9 @@ func GetUserID(c *gin.Context) int {
 		return 0
 	}
 	return userID.(int)
+}
+
+// GetSessionID returns the session of the request's access token.
+func GetSessionID(c *gin.Context) string {
+	return c.GetString("sessionID")
 }
\ No newline at end of file
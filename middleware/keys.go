@@ -0,0 +1,263 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MinHMACSecretLength is the shortest HS256 secret accepted, so a secret
+// cannot be shorter than the 256-bit hash it keys.
+const MinHMACSecretLength = 32
+
+// devSecret signs tokens when no keys are configured. It is only fit for
+// local development.
+const devSecret = "your-secret-key-change-this-in-production"
+
+// Key is one signing key of a key ring. Keys kept only to verify tokens
+// signed before a rotation need no private part.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	sign   interface{}
+	verify interface{}
+}
+
+// NewHMACKey returns an HS256 key, refusing secrets shorter than
+// MinHMACSecretLength. HMAC keys are never published in the JWKS, since
+// anyone who can verify with one can also sign.
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+	if len(secret) < MinHMACSecretLength {
+		return nil, fmt.Errorf("HS256 secrets must be at least %d characters", MinHMACSecretLength)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, sign: secret, verify: secret}, nil
+}
+
+// NewKeyFromPEM returns an EdDSA or RS256 key from a PEM encoded private
+// key, or from a public key for a key that only verifies.
+func NewKeyFromPEM(id, alg string, pemBytes []byte) (*Key, error) {
+	key := &Key{ID: id}
+	var err error
+
+	switch alg {
+	case "EdDSA":
+		key.Method = jwt.SigningMethodEdDSA
+		var private crypto.PrivateKey
+		if private, err = jwt.ParseEdPrivateKeyFromPEM(pemBytes); err == nil {
+			key.sign = private
+			key.verify = private.(ed25519.PrivateKey).Public()
+		} else {
+			key.verify, err = jwt.ParseEdPublicKeyFromPEM(pemBytes)
+		}
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+		var private *rsa.PrivateKey
+		if private, err = jwt.ParseRSAPrivateKeyFromPEM(pemBytes); err == nil {
+			key.sign = private
+			key.verify = &private.PublicKey
+		} else {
+			key.verify, err = jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+		}
+	default:
+		return nil, fmt.Errorf("key %s: unsupported algorithm %q", id, alg)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", id, err)
+	}
+	return key, nil
+}
+
+// KeyRing holds the key new tokens are signed with and every key whose
+// tokens are still accepted. Rotating means adding a new active key while
+// the previous one stays in the ring until its tokens have expired.
+type KeyRing struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+// NewKeyRing returns a ring signing with the key whose ID is active.
+func NewKeyRing(active string, keys ...*Key) (*KeyRing, error) {
+	ring := &KeyRing{keys: map[string]*Key{}}
+	for _, key := range keys {
+		if _, ok := ring.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		ring.keys[key.ID] = key
+	}
+
+	ring.active = ring.keys[active]
+	if ring.active == nil {
+		return nil, fmt.Errorf("active key %q is not in the key ring", active)
+	}
+	if ring.active.sign == nil {
+		return nil, fmt.Errorf("active key %q has no private key", active)
+	}
+	return ring, nil
+}
+
+// DevKeyRing returns the development ring: one HS256 key with a well-known
+// secret.
+func DevKeyRing() *KeyRing {
+	key, _ := NewHMACKey("dev", []byte(devSecret))
+	ring, _ := NewKeyRing("dev", key)
+	return ring
+}
+
+// keyRingFile is the JSON layout read by LoadKeyRing.
+type keyRingFile struct {
+	Active string `json:"active"`
+	Keys   []struct {
+		ID      string `json:"kid"`
+		Alg     string `json:"alg"`
+		Secret  string `json:"secret"`
+		KeyFile string `json:"key_file"`
+	} `json:"keys"`
+}
+
+// LoadKeyRing reads a key ring from a JSON file:
+//
+//	{
+//	  "active": "2025-06",
+//	  "keys": [
+//	    {"kid": "2025-06", "alg": "EdDSA", "key_file": "keys/2025-06.pem"},
+//	    {"kid": "2025-01", "alg": "HS256", "secret": "..."}
+//	  ]
+//	}
+//
+// key_file paths are relative to the key ring file.
+func LoadKeyRing(path string) (*KeyRing, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config keyRingFile
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("invalid key ring %s: %w", path, err)
+	}
+
+	keys := make([]*Key, 0, len(config.Keys))
+	for _, k := range config.Keys {
+		if k.ID == "" {
+			return nil, fmt.Errorf("invalid key ring %s: every key needs a kid", path)
+		}
+
+		if k.Alg == "HS256" {
+			key, err := NewHMACKey(k.ID, []byte(k.Secret))
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", k.ID, err)
+			}
+			keys = append(keys, key)
+			continue
+		}
+
+		keyFile := k.KeyFile
+		if !filepath.IsAbs(keyFile) {
+			keyFile = filepath.Join(filepath.Dir(path), keyFile)
+		}
+		pemBytes, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.ID, err)
+		}
+		key, err := NewKeyFromPEM(k.ID, k.Alg, pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeyRing(config.Active, keys...)
+}
+
+// keyRing is the ring tokens are signed and verified with.
+var keyRing atomic.Pointer[KeyRing]
+
+func init() {
+	keyRing.Store(DevKeyRing())
+}
+
+// UseKeyRing replaces the key ring, normally once at startup.
+func UseKeyRing(ring *KeyRing) {
+	keyRing.Store(ring)
+}
+
+// signToken signs claims with the active key, naming it in the kid header.
+func (r *KeyRing) signToken(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(r.active.Method, claims)
+	token.Header["kid"] = r.active.ID
+	return token.SignedString(r.active.sign)
+}
+
+// verifyKey finds the key a token names and checks the token was signed
+// with that key's algorithm, so a public key is never used as an HMAC
+// secret.
+func (r *KeyRing) verifyKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key := r.keys[kid]
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	return key.verify, nil
+}
+
+// JWK is a public key in JSON Web Key form.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+
+	// Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// CurrentJWKS returns the public keys of the current key ring, so other
+// services can verify tokens. HS256 keys are left out.
+func CurrentJWKS() JWKS {
+	ring := keyRing.Load()
+	jwks := JWKS{Keys: []JWK{}}
+
+	for _, key := range ring.keys {
+		jwk := JWK{KeyID: key.ID, Algorithm: key.Method.Alg(), Use: "sig"}
+		switch public := key.verify.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].KeyID < jwks.Keys[j].KeyID })
+	return jwks
+}
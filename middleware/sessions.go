@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCachedSessions bounds the session cache. Past it, entries that are
+// due for a recheck anyway are dropped.
+const maxCachedSessions = 10000
+
+// SessionStore looks sessions up for the session cache.
+type SessionStore interface {
+	// TouchSession records that a session was used and reports whether it
+	// exists for userID and has not been revoked.
+	TouchSession(sessionID string, userID int) (bool, error)
+}
+
+// SessionCache remembers which sessions were recently found to be active,
+// so a request only reaches the database once per session every ttl. That
+// also bounds how often a session's last-seen time is written.
+//
+// A session revoked through this process is forgotten straight away. One
+// revoked by another process keeps working here for at most ttl.
+type SessionCache struct {
+	store SessionStore
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]sessionEntry
+}
+
+type sessionEntry struct {
+	active    bool
+	checkedAt time.Time
+}
+
+func NewSessionCache(store SessionStore, ttl time.Duration) *SessionCache {
+	return &SessionCache{store: store, ttl: ttl, entries: map[string]sessionEntry{}}
+}
+
+// Active reports whether sessionID of userID may still be used. A revoked
+// session stays revoked, so that answer is kept for good.
+func (c *SessionCache) Active(sessionID string, userID int) (bool, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[sessionID]
+	c.mu.Unlock()
+	if ok && (!entry.active || now.Sub(entry.checkedAt) < c.ttl) {
+		return entry.active, nil
+	}
+
+	active, err := c.store.TouchSession(sessionID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedSessions {
+		for id, e := range c.entries {
+			if e.active && now.Sub(e.checkedAt) >= c.ttl {
+				delete(c.entries, id)
+			}
+		}
+	}
+	c.entries[sessionID] = sessionEntry{active: active, checkedAt: now}
+	return active, nil
+}
+
+// Forget drops a session from the cache, so the next request using it is
+// checked against the store.
+func (c *SessionCache) Forget(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, sessionID)
+}
@@ -1,13 +1,57 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type User struct {
 	ID           int       `json:"id"`
 	Name         string    `json:"name"`
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"-"`
+	Location     *Location `json:"location,omitempty"`
+	DisplayArea  string    `json:"display_area,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
+
+	// InterestHints opts in to seeing, anonymously, the items of people who
+	// liked one of the user's items
+	InterestHints bool `json:"interest_hints"`
+
+	// EmailVerifiedAt is unset until the user confirms their email. Until
+	// then they can browse but not list items, like items or comment.
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+}
+
+// Location is an exact position. It is only ever returned to its owner;
+// other users see the coarse display area and a rounded distance instead.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type LocationRequest struct {
+	Latitude    *float64 `json:"latitude" binding:"required"`
+	Longitude   *float64 `json:"longitude" binding:"required"`
+	DisplayArea string   `json:"display_area"`
+}
+
+// PageRequest selects a page of a list endpoint. Cursor is the NextCursor of
+// the previous page, or empty for the first page.
+type PageRequest struct {
+	Cursor string
+	Limit  int
+}
+
+// Page is the envelope returned by list endpoints. NextCursor is opaque and
+// empty on the last page.
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type InterestHintsRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
 }
 
 type RegisterRequest struct {
@@ -22,42 +66,447 @@ type LoginRequest struct {
 }
 
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	// Token is the short-lived access token, valid for ExpiresIn seconds
+	Token        string `json:"token"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	User         User   `json:"user"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// PasswordReset is a single-use password reset token. Only its hash is kept.
+type PasswordReset struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// EmailVerification is a single-use token confirming a user's email. Only
+// its hash is kept.
+type EmailVerification struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Session is one sign-in of a user on a device. It lives as long as its
+// refresh tokens can be renewed, and revoking it stops its access tokens.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	// Current marks the session the request was made with
+	Current bool `json:"current"`
+}
+
+// SessionTokens are what a sign-in or refresh hands back besides the access
+// token: the session it belongs to and the next refresh token.
+type SessionTokens struct {
+	SessionID    string
+	RefreshToken string
+}
+
+// RefreshToken is a server-side record of an issued refresh token. Only a
+// hash of the token is kept. A session's refresh tokens form a family, whose
+// id is the session id, so a reused token can revoke all of them.
+type RefreshToken struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
 }
 
+// Item lifecycle states. Only available items appear in the swipe deck and can
+// be matched; pending and traded are driven by the match they belong to.
+const (
+	ItemStatusAvailable = "available"
+	ItemStatusPending   = "pending"
+	ItemStatusTraded    = "traded"
+	ItemStatusWithdrawn = "withdrawn"
+)
+
 type Item struct {
-	ID          int       `json:"id"`
-	UserID      int       `json:"user_id"`
-	Title       string    `json:"title" binding:"required"`
-	Description string    `json:"description"`
-	Category    string    `json:"category"`
-	ImageURL    string    `json:"image_url"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID             int       `json:"id"`
+	UserID         int       `json:"user_id"`
+	Title          string    `json:"title" binding:"required"`
+	Description    string    `json:"description"`
+	Category       string    `json:"category"`
+	CategoryID     *int      `json:"category_id,omitempty"`
+	ImageURL       string    `json:"image_url"`
+	Condition      string    `json:"condition,omitempty"`
+	EstimatedValue *int      `json:"estimated_value,omitempty"`
+	Location       *Location `json:"location,omitempty"`
+	DisplayArea    string    `json:"display_area,omitempty"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Category is a node of the category taxonomy. Children is only filled in
+// when categories are returned as a tree.
+type Category struct {
+	ID       int        `json:"id"`
+	Slug     string     `json:"slug"`
+	Name     string     `json:"name"`
+	ParentID *int       `json:"parent_id,omitempty"`
+	Children []Category `json:"children,omitempty"`
+}
+
+// Want describes something a user is looking for. New listings matching it
+// are suggested at the top of the user's deck.
+type Want struct {
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	Keywords      string    `json:"keywords,omitempty"`
+	Category      string    `json:"category,omitempty"`
+	CategoryID    *int      `json:"category_id,omitempty"`
+	MaxDistanceKm *float64  `json:"max_distance_km,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type WantRequest struct {
+	Keywords      string   `json:"keywords"`
+	Category      string   `json:"category"`
+	MaxDistanceKm *float64 `json:"max_distance_km"`
+}
+
+// Item conditions, from best to worst
+const (
+	ItemConditionNew     = "new"
+	ItemConditionLikeNew = "like_new"
+	ItemConditionGood    = "good"
+	ItemConditionFair    = "fair"
+	ItemConditionPoor    = "poor"
+)
+
+// ItemFilter narrows the item feed. Zero values mean "no restriction".
+type ItemFilter struct {
+	ExcludeOwn bool
+	// Categories are slugs or names; each also matches its subcategories
+	Categories    []string
+	Conditions    []string
+	MinValue      *int
+	MaxValue      *int
+	PostedSince   *time.Time
+	ExcludeOwners []int
+	// ExcludeItems were already listed ahead of the feed; the feed cursor
+	// carries them so no later page lists them again.
+	ExcludeItems []int
+
+	// Near is the viewer's position, used to compute distances. With
+	// RadiusKm set, items further away or without a location are left out.
+	Near     *Location
+	RadiusKm *float64
+
+	// Left swipes made before ResurfaceBefore no longer hide an item from
+	// the viewer. When nil, every item the viewer swiped on stays hidden.
+	ResurfaceBefore *time.Time
+}
+
+type ItemStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+}
+
+type UpdateItemRequest struct {
+	Title          *string     `json:"title"`
+	Description    *string     `json:"description"`
+	Category       *string     `json:"category"`
+	ImageURL       *string     `json:"image_url"`
+	Condition      *string     `json:"condition"`
+	EstimatedValue NullableInt `json:"estimated_value"`
+	DisplayArea    *string     `json:"display_area"`
+
+	// Location set to null puts the item back at its owner's location
+	Location NullableLocation `json:"location"`
+
+	// CategoryID is resolved from Category by the service
+	CategoryID *int `json:"-"`
+}
+
+// NullableInt distinguishes a JSON field that is absent (Set is false) from
+// one that is explicitly null (Set is true, Value is nil).
+type NullableInt struct {
+	Set   bool
+	Value *int
+}
+
+func (n *NullableInt) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+	return json.Unmarshal(data, &n.Value)
+}
+
+// NullableLocation is NullableInt for a location.
+type NullableLocation struct {
+	Set   bool
+	Value *Location
+}
+
+func (n *NullableLocation) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+	return json.Unmarshal(data, &n.Value)
+}
+
+type ItemRevision struct {
+	ID        int       `json:"id"`
+	ItemID    int       `json:"item_id"`
+	UserID    int       `json:"user_id"`
+	Field     string    `json:"field"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ItemHistory is the edit history of an item as seen by a particular user.
+// ChangedSinceSwipe is set when the listing was edited after that user swiped on it.
+type ItemHistory struct {
+	ItemID            int            `json:"item_id"`
+	SwipedAt          *time.Time     `json:"swiped_at,omitempty"`
+	ChangedSinceSwipe bool           `json:"changed_since_swipe"`
+	Revisions         []ItemRevision `json:"revisions"`
 }
 
 type ItemWithOwner struct {
 	Item
-	OwnerName string `json:"owner_name"`
+	OwnerName string      `json:"owner_name"`
+	Photos    []ItemPhoto `json:"photos"`
+
+	// DistanceKm is the rounded distance from the viewer, when both have a location
+	DistanceKm *float64 `json:"distance_km,omitempty"`
+
+	// SuggestedFor is the viewer's want this item was suggested for
+	SuggestedFor *Want `json:"suggested_for,omitempty"`
+
+	// OwnerSuperLiked is set when the owner super liked one of the viewer's items
+	OwnerSuperLiked bool `json:"owner_super_liked,omitempty"`
+
+	// InterestHint is set when the owner liked one of the viewer's items but
+	// the viewer has not liked theirs back. The owner's identity is removed
+	// until the like is mutual.
+	InterestHint bool `json:"interest_hint,omitempty"`
+
+	// Similarity is how alike this item is to the one it was listed for, by
+	// who liked them, from 0 to 1
+	Similarity float64 `json:"similarity,omitempty"`
+
+	// Resized renditions of the cover photo, empty when the item has no photos
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	CardURL      string `json:"card_url,omitempty"`
 }
 
+// Deck is the top of a user's ranked swipe deck. Ranker names the strategy
+// that ordered it.
+type Deck struct {
+	Ranker string          `json:"ranker"`
+	Data   []ItemWithOwner `json:"data"`
+}
+
+// ItemSearchResult is an item matched by a keyword search. Snippet is an
+// HTML-escaped excerpt with matched terms wrapped in <mark> tags; lower Rank
+// means more relevant.
+type ItemSearchResult struct {
+	ItemWithOwner
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+// ItemPhoto is an uploaded image attached to an item. Photos are ordered by
+// Position; the first one is the item's cover photo.
+type ItemPhoto struct {
+	ID          int       `json:"id"`
+	ItemID      int       `json:"item_id"`
+	Position    int       `json:"position"`
+	URL         string    `json:"url"`
+	BlobKey     string    `json:"-"`
+	ContentType string    `json:"content_type"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	SizeBytes   int       `json:"size_bytes"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// ThumbnailURL and CardURL fall back to URL for photos without variants
+	ThumbnailURL string         `json:"thumbnail_url"`
+	CardURL      string         `json:"card_url"`
+	Variants     []PhotoVariant `json:"variants"`
+}
+
+// PhotoVariant is a server-generated resized copy of an ItemPhoto.
+type PhotoVariant struct {
+	ID          int    `json:"-"`
+	PhotoID     int    `json:"-"`
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	BlobKey     string `json:"-"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	SizeBytes   int    `json:"size_bytes"`
+}
+
+type PhotoOrderRequest struct {
+	PhotoIDs []int `json:"photo_ids" binding:"required"`
+}
+
+// Swipe directions. A super like counts as a right swipe for matching, and
+// also notifies the item's owner and puts the swiper's items at the top of
+// the owner's deck.
+const (
+	SwipeLeft  = "left"
+	SwipeRight = "right"
+	SwipeSuper = "super"
+)
+
 type Swipe struct {
 	ID        int       `json:"id"`
 	UserID    int       `json:"user_id"`
 	ItemID    int       `json:"item_id" binding:"required"`
-	Direction string    `json:"direction" binding:"required,oneof=left right"`
+	Direction string    `json:"direction" binding:"required,oneof=left right super"`
 	CreatedAt time.Time `json:"created_at"`
+
+	// IdempotencyKey is the client's Idempotency-Key header, if it sent one
+	IdempotencyKey string `json:"-"`
+}
+
+// BatchSwipe is one swipe queued by an offline client. SwipedAt is when it
+// was made on the device; it defaults to the time the batch is received.
+type BatchSwipe struct {
+	ItemID    int        `json:"item_id"`
+	Direction string     `json:"direction"`
+	SwipedAt  *time.Time `json:"swiped_at"`
+}
+
+type BatchSwipeRequest struct {
+	Swipes []BatchSwipe `json:"swipes" binding:"required"`
 }
 
+// Outcomes of a batched swipe
+const (
+	SwipeResultCreated   = "created"
+	SwipeResultDuplicate = "duplicate"
+	SwipeResultConflict  = "conflict"
+	SwipeResultItemGone  = "item_gone"
+	SwipeResultOwnItem   = "own_item"
+	SwipeResultInvalid   = "invalid"
+
+	// SwipeResultUnverified is a right swipe by a user whose email is not
+	// verified yet
+	SwipeResultUnverified = "unverified"
+
+	// SwipeResultSuperUnsupported is a super like, which needs the daily
+	// quota and has to go through POST /swipes
+	SwipeResultSuperUnsupported = "super_not_supported"
+)
+
+// SwipeResult reports what happened to one swipe of a batch. Swipe is the
+// stored swipe, when there is one: the new swipe, or the existing one for a
+// duplicate or conflict.
+type SwipeResult struct {
+	ItemID int    `json:"item_id"`
+	Status string `json:"status"`
+	Swipe  *Swipe `json:"swipe,omitempty"`
+}
+
+// Match states. A proposed match is either accepted or declined; an accepted
+// one is either completed, once both users confirm the trade, or cancelled.
+const (
+	MatchStatusProposed  = "proposed"
+	MatchStatusAccepted  = "accepted"
+	MatchStatusCompleted = "completed"
+	MatchStatusDeclined  = "declined"
+	MatchStatusCancelled = "cancelled"
+)
+
 type Match struct {
 	ID        int       `json:"id"`
 	User1ID   int       `json:"user1_id"`
 	User2ID   int       `json:"user2_id"`
 	Item1ID   int       `json:"item1_id"`
 	Item2ID   int       `json:"item2_id"`
+	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
+	MatchTimestamps
+}
+
+// MatchTimestamps records when a match changed state. User1ConfirmedAt and
+// User2ConfirmedAt are set as each user confirms that the trade happened.
+type MatchTimestamps struct {
+	AcceptedAt       *time.Time `json:"accepted_at,omitempty"`
+	DeclinedAt       *time.Time `json:"declined_at,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+	CancelledAt      *time.Time `json:"cancelled_at,omitempty"`
+	User1ConfirmedAt *time.Time `json:"user1_confirmed_at,omitempty"`
+	User2ConfirmedAt *time.Time `json:"user2_confirmed_at,omitempty"`
+}
+
+// GroupMatch is a ring trade between three or more users, found by following
+// right swipes around a cycle. Every participant has to accept it.
+type GroupMatch struct {
+	ID           int                     `json:"id"`
+	Status       string                  `json:"status"`
+	Participants []GroupMatchParticipant `json:"participants"`
+	CreatedAt    time.Time               `json:"created_at"`
 }
 
+// GroupMatchParticipant is one user's part in a ring trade: the item they
+// hand on and the item they get, which they swiped right on. ConfirmedAt is
+// set once they confirm the accepted trade happened.
+type GroupMatchParticipant struct {
+	UserID            int        `json:"user_id"`
+	UserName          string     `json:"user_name"`
+	Position          int        `json:"position"`
+	GivesItemID       int        `json:"gives_item_id"`
+	GivesItemTitle    string     `json:"gives_item_title"`
+	ReceivesItemID    int        `json:"receives_item_id"`
+	ReceivesItemTitle string     `json:"receives_item_title"`
+	Response          string     `json:"response"`
+	RespondedAt       *time.Time `json:"responded_at,omitempty"`
+	ConfirmedAt       *time.Time `json:"confirmed_at,omitempty"`
+}
+
+// Participant responses to a group match
+const (
+	GroupResponsePending  = "pending"
+	GroupResponseAccepted = "accepted"
+	GroupResponseDeclined = "declined"
+)
+
 type MatchResponse struct {
 	ID         int       `json:"id"`
 	User1ID    int       `json:"user1_id"`
@@ -68,8 +517,10 @@ type MatchResponse struct {
 	Item2Title string    `json:"item2_title"`
 	User1Name  string    `json:"user1_name"`
 	User2Name  string    `json:"user2_name"`
+	Status     string    `json:"status"`
 	CreatedAt  time.Time `json:"created_at"`
-	Comments   []Comment `json:"comments,omitempty"`
+	MatchTimestamps
+	Comments []Comment `json:"comments,omitempty"`
 }
 
 type Comment struct {
@@ -81,7 +532,38 @@ type Comment struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// Block is a user hidden by the viewer. Blocks are only ever listed to the
+// user who made them.
+type Block struct {
+	UserID    int       `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type BlockRequest struct {
+	UserID int `json:"user_id" binding:"required"`
+}
+
+// Notification types
+const (
+	NotificationSuperLike = "super_like"
+)
+
+// Notification tells a user something happened that involves them. ActorID
+// is the user who did it and ItemID the item it concerns.
+type Notification struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	Type      string     `json:"type"`
+	ActorID   int        `json:"actor_id"`
+	ActorName string     `json:"actor_name"`
+	ItemID    int        `json:"item_id"`
+	ItemTitle string     `json:"item_title"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
 type CommentRequest struct {
 	MatchID int    `json:"match_id" binding:"required"`
 	Content string `json:"content" binding:"required"`
-}
\ No newline at end of file
+}
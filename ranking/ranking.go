@@ -0,0 +1,160 @@
+// Package ranking orders the swipe deck. A Ranker turns the signals known
+// about a candidate item into a score; an Experiment splits users between
+// rankers so strategies can be compared.
+package ranking
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Candidate is an item that could be shown to a viewer, with the signals
+// collected for it.
+type Candidate struct {
+	ItemID    int
+	CreatedAt time.Time
+
+	// CategoryAffinity is the share of the viewer's right swipes on items
+	// in this item's category, from 0 to 1
+	CategoryAffinity float64
+
+	// ReciprocalInterest is set when the owner has right swiped one of the
+	// viewer's items
+	ReciprocalInterest bool
+
+	// DistanceKm is nil when either side has no location
+	DistanceKm *float64
+
+	// Similarity is the best similarity between this item and any item the
+	// viewer liked, from 0 to 1
+	Similarity float64
+}
+
+// Ranker scores candidates; higher scores are shown first. Name identifies
+// the strategy in responses and logs.
+type Ranker interface {
+	Name() string
+	Score(c Candidate, now time.Time) float64
+}
+
+// Weighted adds up the signals, each scaled to 0..1 and multiplied by its
+// weight. Recency halves every RecencyHalfLife and distance halves every
+// DistanceHalfKm.
+type Weighted struct {
+	Affinity        float64
+	Similar         float64
+	Reciprocal      float64
+	Recency         float64
+	Distance        float64
+	RecencyHalfLife time.Duration
+	DistanceHalfKm  float64
+}
+
+// DefaultWeighted is the standard deck ranking.
+var DefaultWeighted = Weighted{
+	Affinity:        3,
+	Similar:         2,
+	Reciprocal:      2,
+	Recency:         1,
+	Distance:        1,
+	RecencyHalfLife: 7 * 24 * time.Hour,
+	DistanceHalfKm:  10,
+}
+
+func (w Weighted) Name() string { return "weighted" }
+
+func (w Weighted) Score(c Candidate, now time.Time) float64 {
+	score := w.Affinity*c.CategoryAffinity + w.Similar*c.Similarity
+	if c.ReciprocalInterest {
+		score += w.Reciprocal
+	}
+	if w.RecencyHalfLife > 0 {
+		age := now.Sub(c.CreatedAt)
+		if age < 0 {
+			age = 0
+		}
+		score += w.Recency * math.Exp2(-float64(age)/float64(w.RecencyHalfLife))
+	}
+	if c.DistanceKm != nil && w.DistanceHalfKm > 0 {
+		score += w.Distance * math.Exp2(-*c.DistanceKm/w.DistanceHalfKm)
+	}
+	return score
+}
+
+// Newest ranks by listing time alone, like the plain item feed.
+type Newest struct{}
+
+func (Newest) Name() string { return "newest" }
+
+func (Newest) Score(c Candidate, now time.Time) float64 {
+	return float64(c.CreatedAt.Unix())
+}
+
+// Rank returns candidates ordered by descending score. Ties go to the newer
+// item, then to the higher id, so the order is stable between requests.
+func Rank(r Ranker, candidates []Candidate, now time.Time) []Candidate {
+	scores := make(map[int]float64, len(candidates))
+	for _, c := range candidates {
+		scores[c.ItemID] = r.Score(c, now)
+	}
+
+	ranked := append([]Candidate{}, candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if scores[a.ItemID] != scores[b.ItemID] {
+			return scores[a.ItemID] > scores[b.ItemID]
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ItemID > b.ItemID
+	})
+	return ranked
+}
+
+// Variant is one arm of an experiment. Weight is its share of users relative
+// to the other variants.
+type Variant struct {
+	Ranker Ranker
+	Weight int
+}
+
+// Experiment assigns each user to one of its variants. Assignment depends
+// only on the experiment name and the user id, so a user keeps their
+// variant across requests and restarts.
+type Experiment struct {
+	Name     string
+	Variants []Variant
+}
+
+// Assign returns the ranker for userID, or nil if the experiment has no
+// variant with a positive weight.
+func (e Experiment) Assign(userID int) Ranker {
+	total := 0
+	for _, v := range e.Variants {
+		if v.Weight > 0 {
+			total += v.Weight
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(e.Name + ":" + strconv.Itoa(userID)))
+	bucket := int(h.Sum32() % uint32(total))
+
+	for _, v := range e.Variants {
+		if v.Weight <= 0 {
+			continue
+		}
+		if bucket < v.Weight {
+			return v.Ranker
+		}
+		bucket -= v.Weight
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package ranking
+
+import (
+	"math"
+	"sort"
+)
+
+// Neighbour is an item liked by the same people as another item. Score is
+// the cosine similarity of the two items' sets of likers, from 0 to 1.
+type Neighbour struct {
+	ItemID int
+	Score  float64
+}
+
+// ItemSimilarities builds an item-to-item model from likes, given as the
+// items each user liked. Two items are similar when the same users liked
+// both; pairs liked together by fewer than minCoLikes users are ignored.
+// Each item keeps its k best neighbours, best first, ties to the lower id.
+func ItemSimilarities(likes map[int][]int, k, minCoLikes int) map[int][]Neighbour {
+	likers := map[int]int{}
+	together := map[[2]int]int{}
+
+	for _, items := range likes {
+		items = uniqueSorted(items)
+		for i, a := range items {
+			likers[a]++
+			for _, b := range items[i+1:] {
+				together[[2]int{a, b}]++
+			}
+		}
+	}
+
+	neighbours := map[int][]Neighbour{}
+	for pair, n := range together {
+		if n < minCoLikes {
+			continue
+		}
+		a, b := pair[0], pair[1]
+		score := float64(n) / math.Sqrt(float64(likers[a])*float64(likers[b]))
+		neighbours[a] = append(neighbours[a], Neighbour{ItemID: b, Score: score})
+		neighbours[b] = append(neighbours[b], Neighbour{ItemID: a, Score: score})
+	}
+
+	for item, list := range neighbours {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Score != list[j].Score {
+				return list[i].Score > list[j].Score
+			}
+			return list[i].ItemID < list[j].ItemID
+		})
+		if len(list) > k {
+			list = list[:k]
+		}
+		neighbours[item] = list
+	}
+	return neighbours
+}
+
+func uniqueSorted(items []int) []int {
+	out := append([]int{}, items...)
+	sort.Ints(out)
+	n := 0
+	for i, item := range out {
+		if i == 0 || item != out[i-1] {
+			out[n] = item
+			n++
+		}
+	}
+	return out[:n]
+}
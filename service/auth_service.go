@@ -2,6 +2,7 @@ package service
 
 import (
 	"fmt"
+	"log"
 
 	"github.com/notLeoHirano/bartr/models"
 	"golang.org/x/crypto/bcrypt"
@@ -33,6 +34,11 @@ func (s *Service) Register(req models.RegisterRequest) (*models.User, error) {
 		return nil, err
 	}
 
+	// The account works without it, and the email can be sent again
+	if err := s.SendVerificationEmail(user.ID); err != nil {
+		log.Printf("Error sending verification email to user %d: %v", user.ID, err)
+	}
+
 	return user, nil
 }
 
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/notLeoHirano/bartr/models"
+)
+
+func (s *Service) GetBlocks(userID int) ([]models.Block, error) {
+	return s.repo.GetBlocks(userID)
+}
+
+// BlockUser hides blockedID's items from userID and stops the two from
+// matching or commenting. Their open matches are archived as if userID had
+// unmatched them, so the blocked user sees nothing more than an unmatch.
+func (s *Service) BlockUser(userID, blockedID int) error {
+	if userID == blockedID {
+		return fmt.Errorf("you cannot block yourself")
+	}
+
+	user, err := s.repo.GetUserByID(blockedID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := s.repo.CreateBlock(userID, blockedID); err != nil {
+		return err
+	}
+
+	matches, err := s.repo.GetOpenMatchesBetween(userID, blockedID)
+	if err != nil {
+		return err
+	}
+	for i := range matches {
+		if err := s.archiveMatch(&matches[i], userID); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("User %d blocked user %d", userID, blockedID)
+	return nil
+}
+
+// UnblockUser lifts a block. Archived matches stay archived.
+func (s *Service) UnblockUser(userID, blockedID int) error {
+	deleted, err := s.repo.DeleteBlock(userID, blockedID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("block not found")
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/notLeoHirano/bartr/models"
+)
+
+// GetCategoryTree returns the taxonomy as a list of top-level categories with
+// their subcategories nested under Children.
+func (s *Service) GetCategoryTree() ([]models.Category, error) {
+	categories, err := s.repo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[int][]models.Category{}
+	for _, c := range categories {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	var build func(c models.Category) models.Category
+	build = func(c models.Category) models.Category {
+		for _, child := range children[c.ID] {
+			c.Children = append(c.Children, build(child))
+		}
+		return c
+	}
+
+	tree := []models.Category{}
+	for _, c := range categories {
+		if c.ParentID == nil {
+			tree = append(tree, build(c))
+		}
+	}
+
+	return tree, nil
+}
+
+// resolveCategory maps a category slug or name onto the taxonomy.
+func (s *Service) resolveCategory(ref string) (*models.Category, error) {
+	category, err := s.repo.FindCategory(ref)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		return nil, fmt.Errorf("unknown category")
+	}
+	return category, nil
+}
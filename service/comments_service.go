@@ -10,6 +10,9 @@ func (s *Service) CreateComment(comment *models.Comment) error {
 	if comment.Content == "" {
 		return fmt.Errorf("comment content is required")
 	}
+	if err := s.requireVerified(comment.UserID); err != nil {
+		return err
+	}
 
 	// Verify user is part of the match
 	inMatch, err := s.repo.UserInMatch(comment.MatchID, comment.UserID)
@@ -20,9 +23,29 @@ func (s *Service) CreateComment(comment *models.Comment) error {
 		return fmt.Errorf("you are not part of this match")
 	}
 
+	// Comments between blocked users are refused the same way, so the block
+	// stays invisible to the blocked user
+	match, err := s.repo.GetMatch(comment.MatchID)
+	if err != nil {
+		return err
+	}
+	blocked, err := s.repo.IsBlockedBetween(match.User1ID, match.User2ID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return fmt.Errorf("you are not part of this match")
+	}
+
 	return s.repo.CreateComment(comment)
 }
 
-func (s *Service) GetComments(matchID int) ([]models.Comment, error) {
-	return s.repo.GetComments(matchID)
+func (s *Service) GetComments(matchID int, page models.PageRequest) (*models.Page[models.Comment], error) {
+	page.Limit = clampPageLimit(page.Limit)
+
+	comments, next, err := s.repo.GetComments(matchID, page)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Page[models.Comment]{Data: comments, NextCursor: next}, nil
 }
\ No newline at end of file
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/notLeoHirano/bartr/models"
+	"github.com/notLeoHirano/bartr/ranking"
+)
+
+// MaxDeckCandidates is how many of the newest feed items are considered for
+// ranking on each deck request.
+const MaxDeckCandidates = 200
+
+// DefaultDeckExperiment gives every user the standard weighted ranking.
+var DefaultDeckExperiment = ranking.Experiment{
+	Name:     "deck",
+	Variants: []ranking.Variant{{Ranker: ranking.DefaultWeighted, Weight: 1}},
+}
+
+// GetDeck returns the top of userID's swipe deck: promoted items first, as
+// on the first feed page, then feed items that pass the filter, ordered by
+// the ranker the user is assigned to. There is no cursor; swiped items leave
+// the deck, so the next request returns the next cards.
+func (s *Service) GetDeck(userID int, filter models.ItemFilter, limit int) (*models.Deck, error) {
+	if err := s.validateItemFilter(filter); err != nil {
+		return nil, err
+	}
+	if err := s.locateViewer(userID, &filter); err != nil {
+		return nil, err
+	}
+	filter.ResurfaceBefore = s.resurfaceBefore()
+	filter.ExcludeOwn = true
+	limit = clampPageLimit(limit)
+
+	// Promoted items lead whatever their age and are not ranked again
+	promoted, err := s.promotedItems(userID, filter, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range promoted {
+		filter.ExcludeItems = append(filter.ExcludeItems, item.ID)
+	}
+	limit -= len(promoted)
+
+	items, _, err := s.repo.GetItems(userID, filter, models.PageRequest{Limit: MaxDeckCandidates})
+	if err != nil {
+		return nil, err
+	}
+
+	likedCategories, totalLikes, err := s.repo.GetLikedCategoryCounts(userID)
+	if err != nil {
+		return nil, err
+	}
+	interested, err := s.repo.GetInterestedOwners(userID)
+	if err != nil {
+		return nil, err
+	}
+	similar, err := s.repo.GetLikedNeighbourScores(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]models.ItemWithOwner, len(items))
+	candidates := make([]ranking.Candidate, len(items))
+	for i, item := range items {
+		byID[item.ID] = item
+		c := ranking.Candidate{
+			ItemID:             item.ID,
+			CreatedAt:          item.CreatedAt,
+			ReciprocalInterest: interested[item.UserID],
+			DistanceKm:         item.DistanceKm,
+			Similarity:         similar[item.ID],
+		}
+		if item.CategoryID != nil && totalLikes > 0 {
+			c.CategoryAffinity = float64(likedCategories[*item.CategoryID]) / float64(totalLikes)
+		}
+		candidates[i] = c
+	}
+
+	ranker := s.deckExperiment.Assign(userID)
+	if ranker == nil {
+		ranker = ranking.DefaultWeighted
+	}
+	ranked := ranking.Rank(ranker, candidates, time.Now())
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	deck := &models.Deck{Ranker: ranker.Name(), Data: promoted}
+	for _, c := range ranked {
+		deck.Data = append(deck.Data, byID[c.ItemID])
+	}
+	if err := s.presentItems(userID, deck.Data); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Ranked %d candidate(s) for user %d with %s", len(candidates), userID, ranker.Name())
+	return deck, nil
+}
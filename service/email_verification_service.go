@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/notLeoHirano/bartr/mailer"
+	"github.com/notLeoHirano/bartr/models"
+)
+
+// EmailVerificationTTL is how long an email verification token can be used.
+const EmailVerificationTTL = 48 * time.Hour
+
+// SendVerificationEmail emails userID a token confirming their address.
+func (s *Service) SendVerificationEmail(userID int) error {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+	if user.EmailVerifiedAt != nil {
+		return fmt.Errorf("email is already verified")
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+	v := &models.EmailVerification{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(EmailVerificationTTL),
+	}
+	if err := s.repo.CreateEmailVerification(v); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your Bartr email",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Welcome to Bartr! Use this token to confirm your email address, until %s:\n\n"+
+			"%s\n\n"+
+			"Until then you can look around, but not list items, like items or comment.\n",
+			user.Name, v.ExpiresAt.UTC().Format("2006-01-02 15:04 MST"), token),
+	})
+}
+
+// VerifyEmail confirms a user's email with a token from
+// SendVerificationEmail.
+func (s *Service) VerifyEmail(token string) (*models.User, error) {
+	v, err := s.repo.GetEmailVerification(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if v == nil || v.UsedAt != nil || time.Now().After(v.ExpiresAt) {
+		return nil, fmt.Errorf("invalid or expired verification token")
+	}
+
+	ok, err := s.repo.VerifyEmail(v)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("invalid or expired verification token")
+	}
+
+	log.Printf("User %d verified their email", v.UserID)
+	return s.repo.GetUserByID(v.UserID)
+}
+
+// requireVerified stops users whose email is not verified from doing
+// anything other users see: listing items, liking items and commenting.
+func (s *Service) requireVerified(userID int) error {
+	verified, err := s.emailVerified(userID)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return fmt.Errorf("verify your email address first")
+	}
+	return nil
+}
+
+func (s *Service) emailVerified(userID int) (bool, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil || user == nil {
+		return false, err
+	}
+	return user.EmailVerifiedAt != nil, nil
+}
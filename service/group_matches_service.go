@@ -0,0 +1,196 @@
+package service
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/notLeoHirano/bartr/models"
+	"github.com/notLeoHirano/bartr/tradegraph"
+)
+
+// Ring trades have at least three participants, since two are a plain match,
+// and at most five, beyond which they rarely all come together.
+const (
+	MinTradeCycle = 3
+	MaxTradeCycle = 5
+)
+
+// FindTradeCycles proposes ring trades found in the current right swipes.
+// Cycles are picked shortest first, and no user is put in two new proposals
+// in the same run. It returns how many group matches were created.
+func (s *Service) FindTradeCycles() (int, error) {
+	edges, err := s.repo.GetTradeEdges()
+	if err != nil {
+		return 0, err
+	}
+
+	cycles := tradegraph.SelectDisjoint(tradegraph.FindCycles(edges, MinTradeCycle, MaxTradeCycle))
+
+	created := 0
+	for _, cycle := range cycles {
+		id, err := s.repo.CreateGroupMatch(cycle)
+		if err != nil {
+			return created, err
+		}
+		if id == 0 {
+			continue
+		}
+
+		log.Printf("Group match %d proposed: %s", id, cycle)
+		created++
+	}
+
+	return created, nil
+}
+
+func (s *Service) GetGroupMatches(userID int) ([]models.GroupMatch, error) {
+	return s.repo.GetGroupMatches(userID)
+}
+
+// groupMatchFor loads a group match and checks userID takes part in it.
+func (s *Service) groupMatchFor(groupID, userID int) (*models.GroupMatch, error) {
+	group, err := s.repo.GetGroupMatch(groupID)
+	if err != nil {
+		return nil, err
+	}
+	if group != nil {
+		for _, p := range group.Participants {
+			if p.UserID == userID {
+				return group, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("you are not part of this trade")
+}
+
+// AcceptGroupMatch records userID's acceptance. Once everyone has accepted,
+// the group is accepted and all of its items move to pending.
+func (s *Service) AcceptGroupMatch(groupID, userID int) (*models.GroupMatch, error) {
+	group, err := s.respondToGroupMatch(groupID, userID, models.GroupResponseAccepted)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range group.Participants {
+		if p.Response != models.GroupResponseAccepted {
+			return group, nil
+		}
+	}
+
+	ok, err := s.repo.TransitionGroupMatch(groupID, models.MatchStatusProposed, models.MatchStatusAccepted,
+		models.ItemStatusAvailable, models.ItemStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// Someone traded an item away in the meantime, so the ring is broken
+		if _, err := s.repo.TransitionGroupMatch(groupID, models.MatchStatusProposed, models.MatchStatusCancelled, "", ""); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("items in this trade are no longer available")
+	}
+
+	log.Printf("Group match %d accepted by all participants", groupID)
+	return s.repo.GetGroupMatch(groupID)
+}
+
+// DeclineGroupMatch records userID's refusal, which calls off the whole trade.
+func (s *Service) DeclineGroupMatch(groupID, userID int) (*models.GroupMatch, error) {
+	if _, err := s.respondToGroupMatch(groupID, userID, models.GroupResponseDeclined); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.TransitionGroupMatch(groupID, models.MatchStatusProposed, models.MatchStatusDeclined, "", ""); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Group match %d declined by user %d", groupID, userID)
+	return s.repo.GetGroupMatch(groupID)
+}
+
+// CancelGroupMatch calls off an accepted ring trade before it happened, for
+// everyone, and puts all of its items back on offer.
+func (s *Service) CancelGroupMatch(groupID, userID int) (*models.GroupMatch, error) {
+	group, err := s.groupMatchFor(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if group.Status != models.MatchStatusAccepted {
+		return nil, fmt.Errorf("trade is not %s", models.MatchStatusAccepted)
+	}
+
+	ok, err := s.repo.TransitionGroupMatch(groupID, models.MatchStatusAccepted, models.MatchStatusCancelled,
+		models.ItemStatusPending, models.ItemStatusAvailable)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("trade is not %s", models.MatchStatusAccepted)
+	}
+
+	log.Printf("Group match %d cancelled by user %d", groupID, userID)
+	return s.repo.GetGroupMatch(groupID)
+}
+
+// CompleteGroupMatch records that userID confirms an accepted ring trade
+// happened. Once every participant has confirmed, the group is completed and
+// all of its items traded.
+func (s *Service) CompleteGroupMatch(groupID, userID int) (*models.GroupMatch, error) {
+	group, err := s.groupMatchFor(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if group.Status != models.MatchStatusAccepted {
+		return nil, fmt.Errorf("trade is not %s", models.MatchStatusAccepted)
+	}
+
+	confirmed, err := s.repo.ConfirmGroupMatch(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return nil, fmt.Errorf("you already confirmed this trade")
+	}
+	log.Printf("User %d confirmed group match %d", userID, groupID)
+
+	if group, err = s.repo.GetGroupMatch(groupID); err != nil {
+		return nil, err
+	}
+	for _, p := range group.Participants {
+		if p.ConfirmedAt == nil {
+			return group, nil
+		}
+	}
+
+	ok, err := s.repo.TransitionGroupMatch(groupID, models.MatchStatusAccepted, models.MatchStatusCompleted,
+		models.ItemStatusPending, models.ItemStatusTraded)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("items in this trade are no longer available")
+	}
+
+	log.Printf("Group match %d confirmed by all participants", groupID)
+	return s.repo.GetGroupMatch(groupID)
+}
+
+func (s *Service) respondToGroupMatch(groupID, userID int, response string) (*models.GroupMatch, error) {
+	group, err := s.groupMatchFor(groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if group.Status != models.MatchStatusProposed {
+		return nil, fmt.Errorf("trade is not %s", models.MatchStatusProposed)
+	}
+
+	ok, err := s.repo.RecordGroupResponse(groupID, userID, response)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("you already responded to this trade")
+	}
+
+	return s.repo.GetGroupMatch(groupID)
+}
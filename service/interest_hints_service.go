@@ -0,0 +1,56 @@
+package service
+
+import (
+	"github.com/notLeoHirano/bartr/models"
+)
+
+// MaxInterestHintItems caps how many items of users who liked one of the
+// viewer's items are floated to the top of the first page of the feed.
+const MaxInterestHintItems = 10
+
+// SetInterestHints turns the user's reciprocal interest hints on or off.
+func (s *Service) SetInterestHints(userID int, enabled bool) (*models.User, error) {
+	if err := s.repo.SetInterestHints(userID, enabled); err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserByID(userID)
+}
+
+// interestHintsEnabled reports whether userID opted in to interest hints.
+func (s *Service) interestHintsEnabled(userID int) (bool, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil || user == nil {
+		return false, err
+	}
+	return user.InterestHints, nil
+}
+
+// hiddenLikers returns the users kept anonymous from userID: their one-sided
+// likers, if userID opted in to interest hints, and nobody otherwise.
+func (s *Service) hiddenLikers(userID int) (map[int]bool, error) {
+	hints, err := s.interestHintsEnabled(userID)
+	if err != nil || !hints {
+		return nil, err
+	}
+	return s.repo.GetOneSidedLikers(userID)
+}
+
+// hideLikers flags and anonymizes the items of userID's hidden likers, so
+// the hint cannot be tied back to a person until the like is returned.
+func (s *Service) hideLikers(userID int, items []models.ItemWithOwner) error {
+	likers, err := s.hiddenLikers(userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		if !likers[items[i].UserID] {
+			continue
+		}
+		items[i].InterestHint = true
+		items[i].UserID = 0
+		items[i].OwnerName = ""
+		items[i].DisplayArea = ""
+	}
+	return nil
+}
@@ -2,19 +2,176 @@ package service
 
 import (
 	"fmt"
+	"log"
+	"strings"
 
 	"github.com/notLeoHirano/bartr/models"
 )
 
-func (s *Service) GetItems(userID int, excludeOwn bool) ([]models.ItemWithOwner, error) {
-	return s.repo.GetItems(userID, excludeOwn)
+// itemConditions are the accepted values of Item.Condition.
+var itemConditions = []string{
+	models.ItemConditionNew,
+	models.ItemConditionLikeNew,
+	models.ItemConditionGood,
+	models.ItemConditionFair,
+	models.ItemConditionPoor,
+}
+
+func validCondition(condition string) bool {
+	for _, c := range itemConditions {
+		if c == condition {
+			return true
+		}
+	}
+	return false
+}
+
+// validateItemFields checks the optional condition and estimated value of an item.
+func validateItemFields(condition string, estimatedValue *int) error {
+	if condition != "" && !validCondition(condition) {
+		return fmt.Errorf("invalid condition")
+	}
+	if estimatedValue != nil && *estimatedValue < 0 {
+		return fmt.Errorf("estimated_value cannot be negative")
+	}
+	return nil
+}
+
+func (s *Service) validateItemFilter(filter models.ItemFilter) error {
+	for _, ref := range filter.Categories {
+		if _, err := s.resolveCategory(ref); err != nil {
+			return err
+		}
+	}
+	for _, c := range filter.Conditions {
+		if !validCondition(c) {
+			return fmt.Errorf("invalid condition")
+		}
+	}
+	if filter.MinValue != nil && filter.MaxValue != nil && *filter.MinValue > *filter.MaxValue {
+		return fmt.Errorf("min_value cannot be greater than max_value")
+	}
+	return nil
+}
+
+func (s *Service) GetItems(userID int, filter models.ItemFilter, page models.PageRequest) (*models.Page[models.ItemWithOwner], error) {
+	if err := s.validateItemFilter(filter); err != nil {
+		return nil, err
+	}
+	if err := s.locateViewer(userID, &filter); err != nil {
+		return nil, err
+	}
+	filter.ResurfaceBefore = s.resurfaceBefore()
+	page.Limit = clampPageLimit(page.Limit)
+
+	// Promoted items lead the first page and are left out of the feed on
+	// this and every later page, so nothing is listed twice
+	var promoted []models.ItemWithOwner
+	if page.Cursor == "" {
+		var err error
+		if promoted, err = s.promotedItems(userID, filter, page.Limit-1); err != nil {
+			return nil, err
+		}
+		for _, item := range promoted {
+			filter.ExcludeItems = append(filter.ExcludeItems, item.ID)
+		}
+		page.Limit -= len(promoted)
+	}
+
+	items, next, err := s.repo.GetItems(userID, filter, page)
+	if err != nil {
+		return nil, err
+	}
+	items = append(promoted, items...)
+	if err := s.presentItems(userID, items); err != nil {
+		return nil, err
+	}
+	return &models.Page[models.ItemWithOwner]{Data: items, NextCursor: next}, nil
+}
+
+// presentItems readies items for userID to see. Every path that returns items
+// goes through it, so likers hidden by interest hints stay hidden everywhere.
+func (s *Service) presentItems(userID int, items []models.ItemWithOwner) error {
+	if err := s.hideLikers(userID, items); err != nil {
+		return err
+	}
+	return s.attachPhotos(items)
+}
+
+// promotedItems are floated to the top of userID's first feed page, at most
+// limit of them: items of users who super liked one of userID's items, then
+// with hints on those of one-sided likers, then want suggestions.
+func (s *Service) promotedItems(userID int, filter models.ItemFilter, limit int) ([]models.ItemWithOwner, error) {
+	superLikers, err := s.repo.GetSuperLikerItems(userID, filter, MaxSuperLikerItems)
+	if err != nil {
+		return nil, err
+	}
+	hints, err := s.interestHintsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	var likers []models.ItemWithOwner
+	if hints {
+		if likers, err = s.repo.GetInterestHintItems(userID, filter, MaxInterestHintItems); err != nil {
+			return nil, err
+		}
+	}
+	suggested, err := s.repo.GetSuggestedItems(userID, filter, MaxSuggestions)
+	if err != nil {
+		return nil, err
+	}
+
+	promoted := []models.ItemWithOwner{}
+	seen := map[int]bool{}
+	for _, items := range [][]models.ItemWithOwner{superLikers, likers, suggested} {
+		for _, item := range items {
+			if len(promoted) == limit {
+				return promoted, nil
+			}
+			if !seen[item.ID] {
+				seen[item.ID] = true
+				promoted = append(promoted, item)
+			}
+		}
+	}
+	return promoted, nil
 }
 
 func (s *Service) CreateItem(item *models.Item) error {
 	if item.Title == "" {
 		return fmt.Errorf("title is required")
 	}
-	return s.repo.CreateItem(item)
+	if err := s.requireVerified(item.UserID); err != nil {
+		return err
+	}
+	if err := validateItemFields(item.Condition, item.EstimatedValue); err != nil {
+		return err
+	}
+	item.DisplayArea = strings.TrimSpace(item.DisplayArea)
+	if err := validateLocation(item.Location, item.DisplayArea); err != nil {
+		return err
+	}
+
+	item.CategoryID = nil
+	if strings.TrimSpace(item.Category) == "" {
+		item.Category = ""
+	} else {
+		category, err := s.resolveCategory(item.Category)
+		if err != nil {
+			return err
+		}
+		item.Category = category.Name
+		item.CategoryID = &category.ID
+	}
+	item.Status = models.ItemStatusAvailable
+	if err := s.repo.CreateItem(item); err != nil {
+		return err
+	}
+
+	if err := s.matchWants(item); err != nil {
+		log.Printf("Error matching wants for item %d: %v", item.ID, err)
+	}
+	return nil
 }
 
 func (s *Service) DeleteItem(id int, userID int) error {
@@ -25,5 +182,153 @@ func (s *Service) DeleteItem(id int, userID int) error {
 	if !deleted {
 		return fmt.Errorf("item not found or you don't have permission to delete it")
 	}
-	return nil
-}
\ No newline at end of file
+	return s.deleteItemPhotos(id)
+}
+
+func (s *Service) UpdateItem(id int, userID int, update models.UpdateItemRequest) (*models.Item, error) {
+	if update.Title != nil && strings.TrimSpace(*update.Title) == "" {
+		return nil, fmt.Errorf("title is required")
+	}
+	if update.Title == nil && update.Description == nil && update.Category == nil && update.ImageURL == nil &&
+		update.Condition == nil && !update.EstimatedValue.Set && update.DisplayArea == nil && !update.Location.Set {
+		return nil, fmt.Errorf("no fields to update")
+	}
+	condition := ""
+	if update.Condition != nil {
+		condition = *update.Condition
+	}
+	if err := validateItemFields(condition, update.EstimatedValue.Value); err != nil {
+		return nil, err
+	}
+	displayArea := ""
+	if update.DisplayArea != nil {
+		displayArea = strings.TrimSpace(*update.DisplayArea)
+		update.DisplayArea = &displayArea
+	}
+	if err := validateLocation(update.Location.Value, displayArea); err != nil {
+		return nil, err
+	}
+
+	update.CategoryID = nil
+	if update.Category != nil {
+		name := ""
+		if strings.TrimSpace(*update.Category) != "" {
+			category, err := s.resolveCategory(*update.Category)
+			if err != nil {
+				return nil, err
+			}
+			name = category.Name
+			update.CategoryID = &category.ID
+		}
+		update.Category = &name
+	}
+
+	item, err := s.repo.UpdateItem(id, userID, update)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, fmt.Errorf("item not found or you don't have permission to edit it")
+	}
+	return item, nil
+}
+
+// GetItemHistory returns an item's revisions along with whether it was edited
+// after userID swiped on it.
+func (s *Service) GetItemHistory(itemID int, userID int) (*models.ItemHistory, error) {
+	item, err := s.repo.GetItem(itemID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, fmt.Errorf("item not found")
+	}
+
+	revisions, err := s.repo.GetItemRevisions(itemID)
+	if err != nil {
+		return nil, err
+	}
+	likers, err := s.hiddenLikers(userID)
+	if err != nil {
+		return nil, err
+	}
+	if likers[item.UserID] {
+		for i := range revisions {
+			revisions[i].UserID = 0
+			// hideLikers leaves out the display area too
+			if revisions[i].Field == "display_area" {
+				revisions[i].OldValue, revisions[i].NewValue = "", ""
+			}
+		}
+	}
+
+	swipedAt, err := s.repo.GetSwipeTime(userID, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &models.ItemHistory{
+		ItemID:    itemID,
+		SwipedAt:  swipedAt,
+		Revisions: revisions,
+	}
+	if swipedAt != nil {
+		for _, rev := range revisions {
+			// Timestamps only have second precision, so an edit in the same
+			// second as the swipe is reported as a change.
+			if !rev.CreatedAt.Before(*swipedAt) {
+				history.ChangedSinceSwipe = true
+				break
+			}
+		}
+	}
+
+	return history, nil
+}
+
+// itemTransitions are the status changes an owner may make. Pending and
+// traded items belong to a match and only move along with it; see
+// matchTransitions.
+var itemTransitions = map[string][]string{
+	models.ItemStatusAvailable: {models.ItemStatusWithdrawn},
+	models.ItemStatusWithdrawn: {models.ItemStatusAvailable},
+}
+
+func canTransitionItem(from, to string) bool {
+	for _, next := range itemTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// SetItemStatus lets an owner withdraw an item from the deck or relist it.
+// Pending and traded are only ever set by the match lifecycle.
+func (s *Service) SetItemStatus(id int, userID int, status string) (*models.Item, error) {
+	if status != models.ItemStatusAvailable && status != models.ItemStatusWithdrawn {
+		return nil, fmt.Errorf("status must be 'available' or 'withdrawn'")
+	}
+
+	item, err := s.repo.GetItem(id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil || item.UserID != userID {
+		return nil, fmt.Errorf("item not found or you don't have permission to edit it")
+	}
+	if !canTransitionItem(item.Status, status) {
+		return nil, fmt.Errorf("cannot change item status from '%s' to '%s'", item.Status, status)
+	}
+
+	updated, err := s.repo.SetItemStatus(id, userID, item.Status, status)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, fmt.Errorf("item status changed concurrently, please retry")
+	}
+
+	item.Status = status
+	return item, nil
+}
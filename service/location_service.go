@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/notLeoHirano/bartr/geo"
+	"github.com/notLeoHirano/bartr/models"
+)
+
+const (
+	// MaxRadiusKm bounds radius searches, which get slower the more of the
+	// map they cover.
+	MaxRadiusKm = 250
+
+	maxDisplayAreaLength = 60
+)
+
+func validateLocation(location *models.Location, displayArea string) error {
+	if location != nil && !(geo.Point{Lat: location.Latitude, Lng: location.Longitude}).Valid() {
+		return fmt.Errorf("invalid coordinates")
+	}
+	if utf8.RuneCountInString(displayArea) > maxDisplayAreaLength {
+		return fmt.Errorf("display_area must be at most %d characters", maxDisplayAreaLength)
+	}
+	return nil
+}
+
+// SetLocation records where a user is. Their items without a location of
+// their own are shown at this position, and the display area is the only
+// part other users see.
+func (s *Service) SetLocation(userID int, req models.LocationRequest) (*models.User, error) {
+	location := &models.Location{Latitude: *req.Latitude, Longitude: *req.Longitude}
+	displayArea := strings.TrimSpace(req.DisplayArea)
+	if err := validateLocation(location, displayArea); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetUserLocation(userID, location, displayArea); err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserByID(userID)
+}
+
+func (s *Service) ClearLocation(userID int) error {
+	return s.repo.SetUserLocation(userID, nil, "")
+}
+
+// locateViewer sets filter.Near to the viewer's saved location. A radius
+// filter needs one.
+func (s *Service) locateViewer(userID int, filter *models.ItemFilter) error {
+	if filter.RadiusKm != nil && *filter.RadiusKm > MaxRadiusKm {
+		return fmt.Errorf("radius_km must be at most %d", MaxRadiusKm)
+	}
+
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user != nil {
+		filter.Near = user.Location
+	}
+
+	if filter.RadiusKm != nil && filter.Near == nil {
+		return fmt.Errorf("set your location to filter by distance")
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"log"
+
+	"github.com/notLeoHirano/bartr/models"
+)
+
+func (s *Service) GetNotifications(userID int, page models.PageRequest) (*models.Page[models.Notification], error) {
+	page.Limit = clampPageLimit(page.Limit)
+
+	notifications, next, err := s.repo.GetNotifications(userID, page)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Page[models.Notification]{Data: notifications, NextCursor: next}, nil
+}
+
+func (s *Service) MarkNotificationsRead(userID int) error {
+	return s.repo.MarkNotificationsRead(userID)
+}
+
+// notify records a notification. Failing to notify never fails the action
+// that caused it, so errors are only logged.
+func (s *Service) notify(n *models.Notification) {
+	if err := s.repo.CreateNotification(n); err != nil {
+		log.Printf("Error creating %s notification for user %d: %v", n.Type, n.UserID, err)
+	}
+}
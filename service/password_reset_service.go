@@ -0,0 +1,80 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/notLeoHirano/bartr/mailer"
+	"github.com/notLeoHirano/bartr/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ForgotPassword emails a single-use reset token to the account with this
+// email. It succeeds whether or not there is one, so the response does not
+// tell which emails are registered.
+func (s *Service) ForgotPassword(email string) error {
+	user, err := s.repo.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		log.Printf("Password reset requested for unknown email")
+		return nil
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+	reset := &models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.passwordResetTTL),
+	}
+	if err := s.repo.CreatePasswordReset(reset); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Bartr password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Use this token to choose a new password. It works once, until %s:\n\n"+
+			"%s\n\n"+
+			"If you did not ask to reset your password, you can ignore this email.\n",
+			user.Name, reset.ExpiresAt.UTC().Format("2006-01-02 15:04 MST"), token),
+	})
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword, and
+// signs the user out everywhere.
+func (s *Service) ResetPassword(req models.ResetPasswordRequest) error {
+	reset, err := s.repo.GetPasswordReset(hashToken(req.Token))
+	if err != nil {
+		return err
+	}
+	if reset == nil || reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.repo.ResetPassword(reset, string(hashedPassword))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+
+	n, err := s.RevokeOtherSessions(reset.UserID, "")
+	if err != nil {
+		return err
+	}
+	log.Printf("Password reset for user %d; revoked %d session(s)", reset.UserID, n)
+	return nil
+}
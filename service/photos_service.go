@@ -0,0 +1,433 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/notLeoHirano/bartr/media"
+	"github.com/notLeoHirano/bartr/models"
+)
+
+// MaxPhotosPerItem caps how many photos a single listing can carry.
+const MaxPhotosPerItem = 8
+
+func photoURL(photoID int) string {
+	return fmt.Sprintf("/photos/%d", photoID)
+}
+
+func variantURL(photoID int, name string) string {
+	return fmt.Sprintf("/photos/%d?size=%s", photoID, name)
+}
+
+// decoratePhotos loads the variants of each photo and fills in all URLs.
+func (s *Service) decoratePhotos(photos []models.ItemPhoto) error {
+	ids := make([]int, len(photos))
+	for i, p := range photos {
+		ids[i] = p.ID
+	}
+
+	variants, err := s.repo.GetVariantsForPhotos(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range photos {
+		p := &photos[i]
+		p.URL = photoURL(p.ID)
+		p.ThumbnailURL = p.URL
+		p.CardURL = p.URL
+		p.Variants = variants[p.ID]
+		if p.Variants == nil {
+			p.Variants = []models.PhotoVariant{}
+		}
+
+		for j := range p.Variants {
+			v := &p.Variants[j]
+			v.URL = variantURL(p.ID, v.Name)
+			switch v.Name {
+			case media.VariantThumb:
+				p.ThumbnailURL = v.URL
+			case media.VariantCard:
+				p.CardURL = v.URL
+			}
+		}
+	}
+	return nil
+}
+
+// attachPhotos fills in the Photos of each item, and the cover photo's
+// resized URLs, with a fixed number of queries.
+func (s *Service) attachPhotos(items []models.ItemWithOwner) error {
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+
+	photos, err := s.repo.GetPhotosForItems(ids)
+	if err != nil {
+		return err
+	}
+
+	all := []models.ItemPhoto{}
+	for _, id := range ids {
+		all = append(all, photos[id]...)
+	}
+	if err := s.decoratePhotos(all); err != nil {
+		return err
+	}
+
+	byItem := map[int][]models.ItemPhoto{}
+	for _, p := range all {
+		byItem[p.ItemID] = append(byItem[p.ItemID], p)
+	}
+
+	for i := range items {
+		items[i].Photos = byItem[items[i].ID]
+		if items[i].Photos == nil {
+			items[i].Photos = []models.ItemPhoto{}
+			continue
+		}
+		items[i].ThumbnailURL = items[i].Photos[0].ThumbnailURL
+		items[i].CardURL = items[i].Photos[0].CardURL
+	}
+	return nil
+}
+
+func (s *Service) ownedItem(itemID, userID int) (*models.Item, error) {
+	item, err := s.repo.GetItem(itemID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil || item.UserID != userID {
+		return nil, fmt.Errorf("item not found or you don't have permission to edit it")
+	}
+	return item, nil
+}
+
+// AddItemPhotos validates and stores uploaded images for an item owned by
+// userID. All files are validated before any of them is stored, so a bad file
+// rejects the whole upload.
+func (s *Service) AddItemPhotos(itemID, userID int, files []io.Reader) ([]models.ItemPhoto, error) {
+	if s.blobs == nil {
+		return nil, fmt.Errorf("photo storage is not configured")
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("at least one photo is required")
+	}
+
+	if _, err := s.ownedItem(itemID, userID); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetItemPhotos(itemID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing)+len(files) > MaxPhotosPerItem {
+		return nil, fmt.Errorf("an item can have at most %d photos", MaxPhotosPerItem)
+	}
+
+	images := make([]*media.Image, 0, len(files))
+	for _, f := range files {
+		img, err := media.Sanitize(f)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+
+	photos := make([]models.ItemPhoto, 0, len(images))
+	for _, img := range images {
+		key, err := newBlobKey(fmt.Sprintf("items/%d", itemID), img.Extension)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.blobs.Put(key, bytes.NewReader(img.Data)); err != nil {
+			return nil, fmt.Errorf("error storing photo: %w", err)
+		}
+
+		photo := models.ItemPhoto{
+			ItemID:      itemID,
+			BlobKey:     key,
+			ContentType: img.ContentType,
+			Width:       img.Width,
+			Height:      img.Height,
+			SizeBytes:   len(img.Data),
+		}
+		if err := s.repo.CreateItemPhoto(&photo); err != nil {
+			s.deleteBlob(key)
+			return nil, err
+		}
+
+		// A photo without variants is still usable; the backfill will retry
+		if err := s.storeVariants(&photo, img); err != nil {
+			log.Printf("Error generating variants for photo %d: %v", photo.ID, err)
+		}
+
+		photos = append(photos, photo)
+	}
+
+	if err := s.decoratePhotos(photos); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Stored %d photo(s) for item %d", len(photos), itemID)
+	return photos, nil
+}
+
+func (s *Service) GetItemPhotos(itemID int) ([]models.ItemPhoto, error) {
+	item, err := s.repo.GetItem(itemID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, fmt.Errorf("item not found")
+	}
+
+	photos, err := s.repo.GetItemPhotos(itemID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decoratePhotos(photos); err != nil {
+		return nil, err
+	}
+	return photos, nil
+}
+
+func (s *Service) DeleteItemPhoto(itemID, photoID, userID int) error {
+	if _, err := s.ownedItem(itemID, userID); err != nil {
+		return err
+	}
+
+	photo, err := s.repo.GetItemPhoto(photoID)
+	if err != nil {
+		return err
+	}
+	if photo == nil || photo.ItemID != itemID {
+		return fmt.Errorf("photo not found")
+	}
+
+	variants, err := s.repo.GetVariantsForPhotos([]int{photoID})
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteItemPhoto(photoID); err != nil {
+		return err
+	}
+
+	s.deleteBlob(photo.BlobKey)
+	for _, v := range variants[photoID] {
+		s.deleteBlob(v.BlobKey)
+	}
+	return nil
+}
+
+func (s *Service) ReorderItemPhotos(itemID, userID int, photoIDs []int) ([]models.ItemPhoto, error) {
+	if _, err := s.ownedItem(itemID, userID); err != nil {
+		return nil, err
+	}
+
+	photos, err := s.repo.GetItemPhotos(itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The new order must be a permutation of the item's current photos
+	remaining := map[int]bool{}
+	for _, p := range photos {
+		remaining[p.ID] = true
+	}
+	for _, id := range photoIDs {
+		if !remaining[id] {
+			return nil, fmt.Errorf("photo_ids must list every photo of the item exactly once")
+		}
+		delete(remaining, id)
+	}
+	if len(remaining) > 0 {
+		return nil, fmt.Errorf("photo_ids must list every photo of the item exactly once")
+	}
+
+	if err := s.repo.ReorderItemPhotos(itemID, photoIDs); err != nil {
+		return nil, err
+	}
+
+	return s.GetItemPhotos(itemID)
+}
+
+// OpenPhoto returns a stored image for a photo: the original when size is
+// empty or "original", otherwise the named variant. Photos whose variants
+// have not been generated yet fall back to the original. The caller must
+// close the returned reader.
+func (s *Service) OpenPhoto(photoID int, size string) (contentType string, sizeBytes int, rc io.ReadCloser, err error) {
+	if s.blobs == nil {
+		return "", 0, nil, fmt.Errorf("photo storage is not configured")
+	}
+	if size != "" && size != "original" && size != media.VariantThumb && size != media.VariantCard {
+		return "", 0, nil, fmt.Errorf("size must be one of original, card or thumb")
+	}
+
+	photo, err := s.repo.GetItemPhoto(photoID)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	if photo == nil {
+		return "", 0, nil, fmt.Errorf("photo not found")
+	}
+
+	key, contentType, sizeBytes := photo.BlobKey, photo.ContentType, photo.SizeBytes
+	if size == media.VariantThumb || size == media.VariantCard {
+		variant, err := s.repo.GetPhotoVariant(photoID, size)
+		if err != nil {
+			return "", 0, nil, err
+		}
+		if variant != nil {
+			key, contentType, sizeBytes = variant.BlobKey, variant.ContentType, variant.SizeBytes
+		}
+	}
+
+	rc, err = s.blobs.Open(key)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	return contentType, sizeBytes, rc, nil
+}
+
+// storeVariants renders and saves the resized copies of a freshly stored
+// photo. On failure it removes whatever it had saved so that the photo is
+// picked up again by BackfillPhotoVariants.
+func (s *Service) storeVariants(photo *models.ItemPhoto, img *media.Image) error {
+	rendered, err := media.Variants(img)
+	if err != nil {
+		return err
+	}
+
+	stored := []string{}
+	cleanup := func() {
+		if err := s.repo.DeletePhotoVariants(photo.ID); err != nil {
+			log.Printf("Error removing variants of photo %d: %v", photo.ID, err)
+		}
+		for _, key := range stored {
+			s.deleteBlob(key)
+		}
+	}
+
+	base := strings.TrimSuffix(photo.BlobKey, path.Ext(photo.BlobKey))
+	for _, r := range rendered {
+		key := fmt.Sprintf("%s_%s.%s", base, r.Name, r.Extension)
+		if err := s.blobs.Put(key, bytes.NewReader(r.Data)); err != nil {
+			cleanup()
+			return err
+		}
+		stored = append(stored, key)
+
+		variant := models.PhotoVariant{
+			PhotoID:     photo.ID,
+			Name:        r.Name,
+			BlobKey:     key,
+			ContentType: r.ContentType,
+			Width:       r.Width,
+			Height:      r.Height,
+			SizeBytes:   len(r.Data),
+		}
+		if err := s.repo.CreatePhotoVariant(&variant); err != nil {
+			cleanup()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BackfillPhotoVariants generates variants for photos that have none, such as
+// photos uploaded before variants existed. It returns how many it fixed.
+func (s *Service) BackfillPhotoVariants() (int, error) {
+	if s.blobs == nil {
+		return 0, nil
+	}
+
+	photos, err := s.repo.GetPhotosWithoutVariants()
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for i := range photos {
+		photo := &photos[i]
+
+		rc, err := s.blobs.Open(photo.BlobKey)
+		if err != nil {
+			log.Printf("Error opening photo %d for backfill: %v", photo.ID, err)
+			continue
+		}
+		img, err := media.Sanitize(rc)
+		rc.Close()
+		if err != nil {
+			log.Printf("Error decoding photo %d for backfill: %v", photo.ID, err)
+			continue
+		}
+
+		if err := s.storeVariants(photo, img); err != nil {
+			log.Printf("Error generating variants for photo %d: %v", photo.ID, err)
+			continue
+		}
+		done++
+	}
+
+	return done, nil
+}
+
+// deleteItemPhotos removes every photo of an item, including the stored files.
+func (s *Service) deleteItemPhotos(itemID int) error {
+	photos, err := s.repo.GetItemPhotos(itemID)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, len(photos))
+	for i, p := range photos {
+		ids[i] = p.ID
+	}
+	variants, err := s.repo.GetVariantsForPhotos(ids)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteItemPhotos(itemID); err != nil {
+		return err
+	}
+
+	for _, p := range photos {
+		s.deleteBlob(p.BlobKey)
+		for _, v := range variants[p.ID] {
+			s.deleteBlob(v.BlobKey)
+		}
+	}
+	return nil
+}
+
+// deleteBlob removes a stored file. Failures only leave an orphaned file
+// behind, so they are logged rather than returned.
+func (s *Service) deleteBlob(key string) {
+	if s.blobs == nil {
+		return
+	}
+	if err := s.blobs.Delete(key); err != nil {
+		log.Printf("Error deleting blob %s: %v", key, err)
+	}
+}
+
+func newBlobKey(prefix, ext string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s.%s", prefix, hex.EncodeToString(b), ext), nil
+}
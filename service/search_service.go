@@ -0,0 +1,87 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/notLeoHirano/bartr/models"
+	"github.com/notLeoHirano/bartr/store"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 50
+
+	// maxSearchTerms keeps pathological queries from building huge FTS expressions
+	maxSearchTerms = 10
+)
+
+// SearchItems finds items matching every word of q, treating each word as a
+// prefix so partial words match while typing.
+func (s *Service) SearchItems(userID int, filter models.ItemFilter, q string, limit int) ([]models.ItemSearchResult, error) {
+	match := buildMatchExpression(q)
+	if match == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	if err := s.validateItemFilter(filter); err != nil {
+		return nil, err
+	}
+	if err := s.locateViewer(userID, &filter); err != nil {
+		return nil, err
+	}
+	filter.ResurfaceBefore = s.resurfaceBefore()
+
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+
+	results, err := s.repo.SearchItems(userID, filter, match, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]models.ItemWithOwner, len(results))
+	for i := range results {
+		results[i].Snippet = highlightSnippet(results[i].Snippet)
+		items[i] = results[i].ItemWithOwner
+	}
+	if err := s.presentItems(userID, items); err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].ItemWithOwner = items[i]
+	}
+
+	return results, nil
+}
+
+// buildMatchExpression turns free text into an FTS5 query. Only letters and
+// digits survive, so users cannot inject FTS operators or column filters, and
+// every term becomes a quoted prefix query ("guit"*). Terms are ANDed.
+func buildMatchExpression(q string) string {
+	terms := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = `"` + term + `"*`
+	}
+	return strings.Join(parts, " ")
+}
+
+// highlightSnippet escapes a raw FTS snippet and swaps the match delimiters
+// for <mark> tags, so the result is safe to render as HTML.
+func highlightSnippet(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, store.SnippetMatchStart, "<mark>")
+	return strings.ReplaceAll(escaped, store.SnippetMatchEnd, "</mark>")
+}
@@ -1,13 +1,143 @@
 package service
 
 import (
+	"time"
+
+	"github.com/notLeoHirano/bartr/mailer"
+	"github.com/notLeoHirano/bartr/ranking"
+	"github.com/notLeoHirano/bartr/storage"
 	"github.com/notLeoHirano/bartr/store"
 )
 
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+
+	// DefaultUndoWindow is how long after a swipe it can be taken back.
+	DefaultUndoWindow = 10 * time.Second
+
+	// DefaultLeftSwipeCooldown is how long a left-swiped item stays out of
+	// the deck before it resurfaces.
+	DefaultLeftSwipeCooldown = 30 * 24 * time.Hour
+
+	// DefaultSuperLikesPerDay is how many super likes a user gets per UTC day.
+	DefaultSuperLikesPerDay = 3
+
+	// DefaultRefreshTokenTTL is how long a refresh token can be used.
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	// DefaultPasswordResetTTL is how long a password reset email can be used.
+	DefaultPasswordResetTTL = time.Hour
+)
+
 type Service struct {
-	repo *store.Store
+	repo              *store.Store
+	blobs             storage.BlobStore
+	undoWindow        time.Duration
+	leftSwipeCooldown time.Duration
+	superLikesPerDay  int
+	deckExperiment    ranking.Experiment
+	refreshTokenTTL   time.Duration
+	sessionRevoked    func(sessionID string)
+	mailer            mailer.Mailer
+	passwordResetTTL  time.Duration
+}
+
+// Option configures optional Service dependencies.
+type Option func(*Service)
+
+// WithBlobStore sets where uploaded photos are kept. Without one, photo
+// uploads are rejected.
+func WithBlobStore(blobs storage.BlobStore) Option {
+	return func(s *Service) {
+		s.blobs = blobs
+	}
+}
+
+// WithUndoWindow sets how long after a swipe it can be undone.
+func WithUndoWindow(d time.Duration) Option {
+	return func(s *Service) {
+		s.undoWindow = d
+	}
+}
+
+// WithLeftSwipeCooldown sets how long a left-swiped item stays out of the
+// deck before it can be swiped on again.
+func WithLeftSwipeCooldown(d time.Duration) Option {
+	return func(s *Service) {
+		s.leftSwipeCooldown = d
+	}
+}
+
+// WithSuperLikesPerDay sets the daily super like quota.
+func WithSuperLikesPerDay(n int) Option {
+	return func(s *Service) {
+		s.superLikesPerDay = n
+	}
+}
+
+// WithDeckExperiment sets how users are split between deck rankers.
+func WithDeckExperiment(e ranking.Experiment) Option {
+	return func(s *Service) {
+		s.deckExperiment = e
+	}
+}
+
+// WithRefreshTokenTTL sets how long a refresh token can be used.
+func WithRefreshTokenTTL(d time.Duration) Option {
+	return func(s *Service) {
+		s.refreshTokenTTL = d
+	}
+}
+
+// WithSessionRevoked sets a function told about every revoked session, so
+// caches of active sessions can drop it.
+func WithSessionRevoked(fn func(sessionID string)) Option {
+	return func(s *Service) {
+		s.sessionRevoked = fn
+	}
+}
+
+// WithMailer sets how email is sent. Without one, messages are only logged.
+func WithMailer(m mailer.Mailer) Option {
+	return func(s *Service) {
+		s.mailer = m
+	}
+}
+
+// WithPasswordResetTTL sets how long a password reset email can be used.
+func WithPasswordResetTTL(d time.Duration) Option {
+	return func(s *Service) {
+		s.passwordResetTTL = d
+	}
+}
+
+func New(repo *store.Store, opts ...Option) *Service {
+	s := &Service{
+		repo:              repo,
+		undoWindow:        DefaultUndoWindow,
+		leftSwipeCooldown: DefaultLeftSwipeCooldown,
+		superLikesPerDay:  DefaultSuperLikesPerDay,
+		deckExperiment:    DefaultDeckExperiment,
+		refreshTokenTTL:   DefaultRefreshTokenTTL,
+		passwordResetTTL:  DefaultPasswordResetTTL,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.mailer == nil {
+		s.mailer, _ = mailer.NewLocal("")
+	}
+	return s
 }
 
-func New(repo *store.Store) *Service {
-	return &Service{repo: repo}
+// clampPageLimit applies the default page size and caps oversized requests.
+func clampPageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return limit
 }
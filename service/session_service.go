@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/notLeoHirano/bartr/models"
+)
+
+// maxUserAgentLength caps the user agent recorded for a session.
+const maxUserAgentLength = 255
+
+// GetSessions lists userID's active sessions, marking currentID.
+func (s *Service) GetSessions(userID int, currentID string) ([]models.Session, error) {
+	sessions, err := s.repo.GetSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs one of userID's sessions out.
+func (s *Service) RevokeSession(userID int, sessionID string) error {
+	revoked, err := s.revokeSessions(userID, []string{sessionID}, "")
+	if err != nil {
+		return err
+	}
+	if len(revoked) == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+// RevokeOtherSessions signs userID out everywhere but currentID, and
+// returns how many sessions were ended. An empty currentID ends them all.
+func (s *Service) RevokeOtherSessions(userID int, currentID string) (int, error) {
+	revoked, err := s.revokeSessions(userID, nil, currentID)
+	return len(revoked), err
+}
+
+// revokeSessions revokes sessions in the store and tells the session cache.
+func (s *Service) revokeSessions(userID int, ids []string, keepID string) ([]string, error) {
+	revoked, err := s.repo.RevokeSessions(userID, ids, keepID)
+	if err != nil {
+		return nil, err
+	}
+	if s.sessionRevoked != nil {
+		for _, id := range revoked {
+			s.sessionRevoked(id)
+		}
+	}
+	return revoked, nil
+}
+
+// truncate cuts s to at most n bytes.
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
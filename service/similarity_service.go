@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/notLeoHirano/bartr/models"
+	"github.com/notLeoHirano/bartr/ranking"
+)
+
+const (
+	// SimilarNeighbours is how many similar items are kept per item.
+	SimilarNeighbours = 20
+	// MinCoLikes is how many users must have liked two items for them to
+	// count as similar.
+	MinCoLikes = 2
+	// MaxLikesPerUser bounds how many of a user's recent likes feed the model.
+	MaxLikesPerUser = 200
+)
+
+// BuildItemSimilarities rebuilds the "people who liked this also liked"
+// model from right swipes and returns how many items have neighbours.
+func (s *Service) BuildItemSimilarities() (int, error) {
+	likes, err := s.repo.GetLikesByUser(MaxLikesPerUser)
+	if err != nil {
+		return 0, err
+	}
+
+	neighbours := ranking.ItemSimilarities(likes, SimilarNeighbours, MinCoLikes)
+	if err := s.repo.ReplaceItemSimilarities(neighbours); err != nil {
+		return 0, err
+	}
+
+	log.Printf("Built similarities for %d item(s) from %d user(s)", len(neighbours), len(likes))
+	return len(neighbours), nil
+}
+
+// GetSimilarItems lists items liked by the same people as itemID that
+// userID could still trade for.
+func (s *Service) GetSimilarItems(itemID, userID, limit int) ([]models.ItemWithOwner, error) {
+	item, err := s.repo.GetItem(itemID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, fmt.Errorf("item not found")
+	}
+
+	var filter models.ItemFilter
+	if err := s.locateViewer(userID, &filter); err != nil {
+		return nil, err
+	}
+
+	items, err := s.repo.GetSimilarItems(itemID, userID, filter, clampPageLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.presentItems(userID, items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -3,72 +3,474 @@ package service
 import (
 	"fmt"
 	"log"
+	"sort"
+	"time"
 
 	"github.com/notLeoHirano/bartr/models"
 )
 
-func (s *Service) CreateSwipe(swipe *models.Swipe) error {
-	if swipe.Direction != "left" && swipe.Direction != "right" {
-		return fmt.Errorf("direction must be 'left' or 'right'")
+// CreateSwipe records a swipe and reports whether it was new. Repeating a
+// swipe, or replaying one with the same idempotency key, is not an error:
+// swipe is replaced with the stored swipe and false is returned. A left
+// swipe older than the cooldown is replaced by the new swipe. A super like
+// spends one of the user's daily super likes and notifies the item's owner.
+func (s *Service) CreateSwipe(swipe *models.Swipe) (bool, error) {
+	if swipe.Direction != models.SwipeLeft && swipe.Direction != models.SwipeRight && swipe.Direction != models.SwipeSuper {
+		return false, fmt.Errorf("direction must be 'left', 'right' or 'super'")
+	}
+	if isLike(swipe.Direction) {
+		if err := s.requireVerified(swipe.UserID); err != nil {
+			return false, err
+		}
 	}
 
-	if err := s.repo.CreateSwipe(swipe); err != nil {
-		return err
+	if swipe.IdempotencyKey != "" {
+		existing, err := s.repo.GetSwipeByKey(swipe.UserID, swipe.IdempotencyKey)
+		if err != nil {
+			return false, err
+		}
+		if existing != nil {
+			if existing.ItemID != swipe.ItemID || existing.Direction != swipe.Direction {
+				return false, fmt.Errorf("idempotency key was already used for a different swipe")
+			}
+			*swipe = *existing
+			return false, nil
+		}
+	}
+
+	existing, err := s.repo.GetSwipe(swipe.UserID, swipe.ItemID)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil && !s.resurfaced(existing) {
+		return false, s.repeatSwipe(swipe, existing)
+	}
+
+	item, err := s.repo.GetItem(swipe.ItemID)
+	if err != nil {
+		return false, err
+	}
+	if item == nil {
+		return false, fmt.Errorf("item not found")
+	}
+	if item.Status != models.ItemStatusAvailable {
+		return false, fmt.Errorf("item is not available")
+	}
+
+	var superLikeDay string
+	if swipe.Direction == models.SwipeSuper {
+		if item.UserID == swipe.UserID {
+			return false, fmt.Errorf("you cannot super like your own item")
+		}
+		superLikeDay = time.Now().UTC().Format("2006-01-02")
+		if err := s.useSuperLike(swipe.UserID, superLikeDay); err != nil {
+			return false, err
+		}
+	}
+
+	created, err := s.storeSwipe(swipe, existing)
+	if superLikeDay != "" && (err != nil || !created) {
+		if err := s.repo.RefundSuperLike(swipe.UserID, superLikeDay); err != nil {
+			log.Printf("Error refunding super like: %v", err)
+		}
+	}
+	if err != nil || !created {
+		return false, err
 	}
 
 	log.Printf("User %d swiped %s on item %d", swipe.UserID, swipe.Direction, swipe.ItemID)
 
+	// Blocks stay invisible, so the super like itself goes through; the
+	// owner is just not told about it
+	if swipe.Direction == models.SwipeSuper {
+		blocked, err := s.repo.IsBlockedBetween(swipe.UserID, item.UserID)
+		if err != nil {
+			log.Printf("Error checking blocks: %v", err)
+		} else if !blocked {
+			s.notify(&models.Notification{
+				UserID:  item.UserID,
+				Type:    models.NotificationSuperLike,
+				ActorID: swipe.UserID,
+				ItemID:  item.ID,
+			})
+		}
+	}
+
 	// Check for matches only if swipe was right
-	if swipe.Direction == "right" {
+	if isLike(swipe.Direction) {
 		if err := s.checkAndCreateMatches(swipe.UserID, swipe.ItemID); err != nil {
 			log.Printf("Error checking for matches: %v", err)
 		}
 	}
 
+	return true, nil
+}
+
+// storeSwipe writes a new swipe, or replaces a resurfaced one. It returns
+// false if a concurrent request stored a swipe on the item first, in which
+// case it answers as for a repeated swipe.
+func (s *Service) storeSwipe(swipe, existing *models.Swipe) (bool, error) {
+	if existing != nil {
+		swipe.ID = existing.ID
+		return true, s.repo.ResetSwipe(swipe)
+	}
+
+	created, err := s.repo.CreateSwipe(swipe)
+	if err != nil || created {
+		return created, err
+	}
+
+	// Lost a race with a concurrent request for the same item or key
+	if existing, err = s.repo.GetSwipe(swipe.UserID, swipe.ItemID); err != nil {
+		return false, err
+	}
+	if existing == nil {
+		return false, fmt.Errorf("idempotency key was already used for a different swipe")
+	}
+	return false, s.repeatSwipe(swipe, existing)
+}
+
+// useSuperLike spends one of userID's super likes for day.
+func (s *Service) useSuperLike(userID int, day string) error {
+	ok := false
+	if s.superLikesPerDay > 0 {
+		var err error
+		if ok, err = s.repo.UseSuperLike(userID, day, s.superLikesPerDay); err != nil {
+			return err
+		}
+	}
+	if !ok {
+		return fmt.Errorf("you have used all %d super likes for today", s.superLikesPerDay)
+	}
+	return nil
+}
+
+// MaxSuperLikerItems caps how many items of users who super liked the viewer
+// are floated to the top of the first page of the deck.
+const MaxSuperLikerItems = 10
+
+// isLike reports whether a swipe direction counts as liking the item.
+func isLike(direction string) bool {
+	return direction == models.SwipeRight || direction == models.SwipeSuper
+}
+
+// repeatSwipe answers a swipe on an item the user already swiped on: the
+// same direction is a harmless repeat, the other is a conflict. Either way
+// swipe is replaced with the existing swipe.
+func (s *Service) repeatSwipe(swipe, existing *models.Swipe) error {
+	direction := swipe.Direction
+	*swipe = *existing
+	if existing.Direction != direction {
+		return fmt.Errorf("you already swiped %s on this item", existing.Direction)
+	}
 	return nil
 }
 
-func (s *Service) checkAndCreateMatches(swipingUserID, swipedItemID int) error {
-	print("checking for matches")
-	itemOwnerID, err := s.repo.GetItemOwnerID(swipedItemID)
+// resurfaced reports whether a left swipe is old enough for the item to be
+// swiped on again.
+func (s *Service) resurfaced(swipe *models.Swipe) bool {
+	return swipe.Direction == models.SwipeLeft && time.Since(swipe.CreatedAt) >= s.leftSwipeCooldown
+}
+
+// resurfaceBefore is the cutoff for left swipes that no longer hide items.
+func (s *Service) resurfaceBefore() *time.Time {
+	cutoff := time.Now().Add(-s.leftSwipeCooldown)
+	return &cutoff
+}
+
+// UndoSwipe takes back userID's most recent swipe if it is within the undo
+// window. Matches the swipe produced are removed with it, as long as nobody
+// has commented on them or moved them on from proposed; otherwise the swipe
+// stands. An undone super like is given back.
+func (s *Service) UndoSwipe(userID int) (*models.Swipe, error) {
+	swipe, err := s.repo.GetLastSwipe(userID)
+	if err != nil {
+		return nil, err
+	}
+	if swipe == nil {
+		return nil, fmt.Errorf("no swipe to undo")
+	}
+	if time.Since(swipe.CreatedAt) > s.undoWindow {
+		return nil, fmt.Errorf("swipe is too old to undo")
+	}
+
+	ok, err := s.repo.UndoSwipe(swipe)
 	if err != nil {
-		return fmt.Errorf("error finding item owner: %w", err)
+		return nil, err
 	}
+	if !ok {
+		return nil, fmt.Errorf("swipe already led to a trade in progress")
+	}
+	if swipe.Direction == models.SwipeSuper {
+		if err := s.repo.RefundSuperLike(userID, swipe.CreatedAt.UTC().Format("2006-01-02")); err != nil {
+			log.Printf("Error refunding super like: %v", err)
+		}
+	}
+
+	log.Printf("User %d undid their %s swipe on item %d", userID, swipe.Direction, swipe.ItemID)
+	return swipe, nil
+}
 
-	userItems, err := s.repo.GetItems(swipingUserID, false)
+// checkAndCreateMatches looks for matches created by the user's right
+// swipes on the given items. The user's own items are loaded once, so a
+// whole batch of swipes is checked together.
+func (s *Service) checkAndCreateMatches(swipingUserID int, swipedItemIDs ...int) error {
+	print("checking for matches")
+	userItems, err := s.repo.GetUserItems(swipingUserID)
 	if err != nil {
 		return fmt.Errorf("error fetching user's items: %w", err)
 	}
 
-	for _, userItem := range userItems {
-		if userItem.UserID != swipingUserID {
-			continue
+	for _, swipedItemID := range swipedItemIDs {
+		itemOwnerID, err := s.repo.GetItemOwnerID(swipedItemID)
+		if err != nil {
+			return fmt.Errorf("error finding item owner: %w", err)
 		}
 
-		// Check if the item owner already swiped right on this user's item
-		ownerSwiped, err := s.repo.UserSwipedRight(itemOwnerID, userItem.ID)
-		if err != nil {
-			log.Printf("Error checking swipe: %v", err)
+		for _, userItem := range userItems {
+			if userItem.Status != models.ItemStatusAvailable {
+				continue
+			}
+
+			// Check if the item owner already swiped right on this user's item
+			ownerSwiped, err := s.repo.UserSwipedRight(itemOwnerID, userItem.ID)
+			if err != nil {
+				log.Printf("Error checking swipe: %v", err)
+				continue
+			}
+			if !ownerSwiped {
+				continue
+			}
+
+			// Create match using transaction
+			if err := s.repo.CreateMatchIfNeeded(swipingUserID, itemOwnerID, userItem.ID, swipedItemID); err != nil {
+				log.Printf("Error creating match: %v", err)
+				continue
+			}
+
+			log.Printf("Match created! User %d item %d <-> User %d item %d",
+				swipingUserID, userItem.ID, itemOwnerID, swipedItemID)
+		}
+	}
+
+	return nil
+}
+
+// MaxSwipeBatch is the most swipes a client can submit at once.
+const MaxSwipeBatch = 100
+
+// MaxOfflineSwipeAge is how far back a batched swipe may be dated. Older
+// swiped_at values are not trusted and the swipe is dated on arrival.
+const MaxOfflineSwipeAge = 7 * 24 * time.Hour
+
+// CreateSwipeBatch records swipes queued by an offline client in a single
+// transaction, in the order they were made, and returns a result for each in
+// the order submitted. Matches are looked for once the whole batch is stored.
+func (s *Service) CreateSwipeBatch(userID int, batch []models.BatchSwipe) ([]models.SwipeResult, error) {
+	if len(batch) == 0 {
+		return nil, fmt.Errorf("a batch needs at least one swipe")
+	}
+	if len(batch) > MaxSwipeBatch {
+		return nil, fmt.Errorf("a batch can have at most %d swipes", MaxSwipeBatch)
+	}
+
+	verified, err := s.emailVerified(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	results := make([]models.SwipeResult, len(batch))
+	type queued struct {
+		position int
+		swipe    models.Swipe
+	}
+	var valid []queued
+	for i, b := range batch {
+		switch b.Direction {
+		case models.SwipeLeft, models.SwipeRight:
+		case models.SwipeSuper:
+			results[i] = models.SwipeResult{ItemID: b.ItemID, Status: models.SwipeResultSuperUnsupported}
+			continue
+		default:
+			results[i] = models.SwipeResult{ItemID: b.ItemID, Status: models.SwipeResultInvalid}
 			continue
 		}
-		if !ownerSwiped {
+		if b.Direction == models.SwipeRight && !verified {
+			results[i] = models.SwipeResult{ItemID: b.ItemID, Status: models.SwipeResultUnverified}
 			continue
 		}
 
-		// Create match using transaction
-		if err := s.repo.CreateMatchIfNeeded(swipingUserID, itemOwnerID, userItem.ID, swipedItemID); err != nil {
-			log.Printf("Error creating match: %v", err)
-			continue
+		// Device clocks drift, so swipes are never dated in the future, nor
+		// further back than a client could plausibly have been offline
+		swipedAt := now
+		if b.SwipedAt != nil && b.SwipedAt.Before(now) && b.SwipedAt.After(now.Add(-MaxOfflineSwipeAge)) {
+			swipedAt = *b.SwipedAt
+		}
+		valid = append(valid, queued{i, models.Swipe{ItemID: b.ItemID, Direction: b.Direction, CreatedAt: swipedAt}})
+	}
+
+	sort.SliceStable(valid, func(i, j int) bool { return valid[i].swipe.CreatedAt.Before(valid[j].swipe.CreatedAt) })
+	swipes := make([]models.Swipe, len(valid))
+	for i, q := range valid {
+		swipes[i] = q.swipe
+	}
+
+	stored, err := s.repo.CreateSwipeBatch(userID, swipes, s.leftSwipeCooldown)
+	if err != nil {
+		return nil, err
+	}
+
+	var liked []int
+	for i, result := range stored {
+		results[valid[i].position] = result
+		if result.Status == models.SwipeResultCreated && isLike(result.Swipe.Direction) {
+			liked = append(liked, result.ItemID)
+		}
+	}
+	log.Printf("User %d submitted %d swipe(s), %d right", userID, len(batch), len(liked))
+
+	if len(liked) > 0 {
+		if err := s.checkAndCreateMatches(userID, liked...); err != nil {
+			log.Printf("Error checking for matches: %v", err)
 		}
+	}
+
+	return results, nil
+}
+
+
+func (s *Service) GetMatches(userID int, page models.PageRequest) (*models.Page[models.MatchResponse], error) {
+	page.Limit = clampPageLimit(page.Limit)
+
+	matches, next, err := s.repo.GetMatches(userID, page)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Page[models.MatchResponse]{Data: matches, NextCursor: next}, nil
+}
+
+// matchTransitions gives, for each status a match can be moved to, the
+// status it has to be in and how its items move along with it. An empty
+// itemFrom leaves the items alone.
+var matchTransitions = map[string]struct{ from, itemFrom, itemTo string }{
+	models.MatchStatusAccepted:  {models.MatchStatusProposed, models.ItemStatusAvailable, models.ItemStatusPending},
+	models.MatchStatusDeclined:  {models.MatchStatusProposed, "", ""},
+	models.MatchStatusCompleted: {models.MatchStatusAccepted, models.ItemStatusPending, models.ItemStatusTraded},
+	models.MatchStatusCancelled: {models.MatchStatusAccepted, models.ItemStatusPending, models.ItemStatusAvailable},
+}
+
+// AcceptMatch reserves both items of a proposed match by moving them to pending.
+func (s *Service) AcceptMatch(matchID, userID int) (*models.Match, error) {
+	return s.transitionMatch(matchID, userID, models.MatchStatusAccepted)
+}
+
+// DeclineMatch turns down a proposed match. Its items stay available.
+func (s *Service) DeclineMatch(matchID, userID int) (*models.Match, error) {
+	return s.transitionMatch(matchID, userID, models.MatchStatusDeclined)
+}
+
+// CancelMatch calls off an accepted trade and puts both items back on offer.
+func (s *Service) CancelMatch(matchID, userID int) (*models.Match, error) {
+	return s.transitionMatch(matchID, userID, models.MatchStatusCancelled)
+}
+
+// CompleteMatch records that userID confirms an accepted trade happened. Once
+// both users have confirmed, the match is completed and both items traded.
+func (s *Service) CompleteMatch(matchID, userID int) (*models.Match, error) {
+	match, err := s.matchFor(matchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if match.Status != models.MatchStatusAccepted {
+		return nil, fmt.Errorf("match is not %s", models.MatchStatusAccepted)
+	}
+
+	confirmed, err := s.repo.ConfirmMatch(matchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return nil, fmt.Errorf("you already confirmed this trade")
+	}
+	log.Printf("User %d confirmed match %d", userID, matchID)
+
+	if match, err = s.repo.GetMatch(matchID); err != nil {
+		return nil, err
+	}
+	if match.User1ConfirmedAt == nil || match.User2ConfirmedAt == nil {
+		return match, nil
+	}
+	return s.applyMatchTransition(match, userID, models.MatchStatusCompleted)
+}
+
+// Unmatch archives a match and its comments for both users. An open match is
+// closed on the way out: a proposed one is declined and an accepted one is
+// cancelled, putting its items back on offer.
+func (s *Service) Unmatch(matchID, userID int) error {
+	match, err := s.matchFor(matchID, userID)
+	if err != nil {
+		return err
+	}
+	return s.archiveMatch(match, userID)
+}
+
+func (s *Service) archiveMatch(match *models.Match, userID int) error {
+	var to string
+	switch match.Status {
+	case models.MatchStatusProposed:
+		to = models.MatchStatusDeclined
+	case models.MatchStatusAccepted:
+		to = models.MatchStatusCancelled
+	}
 
-		log.Printf("Match created! User %d item %d <-> User %d item %d",
-			swipingUserID, userItem.ID, itemOwnerID, swipedItemID)
+	var t struct{ from, itemFrom, itemTo string }
+	if to != "" {
+		t = matchTransitions[to]
+	}
+	if err := s.repo.ArchiveMatch(match.ID, t.from, to, t.itemFrom, t.itemTo); err != nil {
+		return err
 	}
 
+	log.Printf("Match %d archived by user %d", match.ID, userID)
 	return nil
 }
 
+// matchFor returns a match userID is part of.
+func (s *Service) matchFor(matchID, userID int) (*models.Match, error) {
+	inMatch, err := s.repo.UserInMatch(matchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !inMatch {
+		return nil, fmt.Errorf("you are not part of this match")
+	}
+
+	return s.repo.GetMatch(matchID)
+}
 
-func (s *Service) GetMatches(userID int) ([]models.MatchResponse, error) {
-	return s.repo.GetMatches(userID)
-}
\ No newline at end of file
+func (s *Service) transitionMatch(matchID, userID int, to string) (*models.Match, error) {
+	match, err := s.matchFor(matchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.applyMatchTransition(match, userID, to)
+}
+
+func (s *Service) applyMatchTransition(match *models.Match, userID int, to string) (*models.Match, error) {
+	t := matchTransitions[to]
+	if match.Status != t.from {
+		return nil, fmt.Errorf("match is not %s", t.from)
+	}
+
+	ok, err := s.repo.TransitionMatch(match.ID, t.from, to, t.itemFrom, t.itemTo)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("items in this match are no longer available")
+	}
+
+	log.Printf("Match %d moved from %s to %s by user %d", match.ID, t.from, to, userID)
+	return s.repo.GetMatch(match.ID)
+}
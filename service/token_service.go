@@ -0,0 +1,133 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/notLeoHirano/bartr/models"
+)
+
+// newToken returns a random opaque token and the hash to store for it.
+func newToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken is how opaque tokens are stored, so a leaked database does not
+// leak usable tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// StartSession signs userID in on a new device and returns the session
+// with its first refresh token.
+func (s *Service) StartSession(userID int, userAgent, ipAddress string) (*models.SessionTokens, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	token, hash, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		UserAgent: truncate(userAgent, maxUserAgentLength),
+		IPAddress: ipAddress,
+	}
+	refresh := &models.RefreshToken{
+		UserID:    userID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.refreshTokenTTL),
+	}
+	if err := s.repo.CreateSession(session, refresh); err != nil {
+		return nil, err
+	}
+	return &models.SessionTokens{SessionID: session.ID, RefreshToken: token}, nil
+}
+
+// RefreshSession exchanges a refresh token for a new one and returns the
+// user to issue an access token for. Each refresh token works once: using
+// one again means it was copied, so its whole session is revoked.
+func (s *Service) RefreshSession(refreshToken, userAgent, ipAddress string) (*models.User, *models.SessionTokens, error) {
+	old, err := s.repo.GetRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		return nil, nil, err
+	}
+	if old == nil || old.RevokedAt != nil {
+		return nil, nil, fmt.Errorf("invalid refresh token")
+	}
+	if old.UsedAt != nil {
+		return nil, nil, s.refreshTokenReused(old)
+	}
+	if time.Now().After(old.ExpiresAt) {
+		return nil, nil, fmt.Errorf("refresh token has expired")
+	}
+
+	user, err := s.repo.GetUserByID(old.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, fmt.Errorf("invalid refresh token")
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	next := &models.RefreshToken{
+		UserID:    old.UserID,
+		FamilyID:  old.FamilyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.refreshTokenTTL),
+	}
+	rotated, err := s.repo.RotateRefreshToken(old, next)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !rotated {
+		return nil, nil, s.refreshTokenReused(old)
+	}
+
+	if err := s.repo.UpdateSessionDevice(old.FamilyID, truncate(userAgent, maxUserAgentLength), ipAddress); err != nil {
+		log.Printf("Error updating session %s: %v", old.FamilyID, err)
+	}
+	return user, &models.SessionTokens{SessionID: old.FamilyID, RefreshToken: token}, nil
+}
+
+// refreshTokenReused revokes the session of a refresh token presented
+// after it was already exchanged.
+func (s *Service) refreshTokenReused(token *models.RefreshToken) error {
+	log.Printf("Refresh token %d of user %d was reused; revoking session %s", token.ID, token.UserID, token.FamilyID)
+	if _, err := s.revokeSessions(token.UserID, []string{token.FamilyID}, ""); err != nil {
+		return err
+	}
+	return fmt.Errorf("refresh token was already used")
+}
+
+// Logout ends the session of a refresh token: the token can no longer be
+// renewed and the session's access tokens stop working.
+func (s *Service) Logout(refreshToken string) error {
+	token, err := s.repo.GetRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return fmt.Errorf("invalid refresh token")
+	}
+	_, err = s.revokeSessions(token.UserID, []string{token.FamilyID}, "")
+	return err
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/notLeoHirano/bartr/geo"
+	"github.com/notLeoHirano/bartr/models"
+)
+
+const (
+	MaxWantsPerUser = 20
+
+	// MaxSuggestions caps how many want suggestions lead the first feed page.
+	MaxSuggestions = 10
+)
+
+func (s *Service) GetWants(userID int) ([]models.Want, error) {
+	return s.repo.GetWants(userID)
+}
+
+func (s *Service) CreateWant(userID int, req models.WantRequest) (*models.Want, error) {
+	want := &models.Want{
+		UserID:        userID,
+		Keywords:      strings.TrimSpace(req.Keywords),
+		MaxDistanceKm: req.MaxDistanceKm,
+	}
+
+	if want.Keywords == "" && strings.TrimSpace(req.Category) == "" {
+		return nil, fmt.Errorf("a want needs keywords or a category")
+	}
+	if want.Keywords != "" && buildMatchExpression(want.Keywords) == "" {
+		return nil, fmt.Errorf("keywords must contain letters or digits")
+	}
+	if d := want.MaxDistanceKm; d != nil && (!(*d > 0) || *d > MaxRadiusKm) {
+		return nil, fmt.Errorf("max_distance_km must be between 0 and %d", MaxRadiusKm)
+	}
+
+	if strings.TrimSpace(req.Category) != "" {
+		category, err := s.resolveCategory(req.Category)
+		if err != nil {
+			return nil, err
+		}
+		want.Category = category.Name
+		want.CategoryID = &category.ID
+	}
+
+	count, err := s.repo.CountWants(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxWantsPerUser {
+		return nil, fmt.Errorf("you can have at most %d wants", MaxWantsPerUser)
+	}
+
+	if err := s.repo.CreateWant(want); err != nil {
+		return nil, err
+	}
+	return want, nil
+}
+
+func (s *Service) DeleteWant(id int, userID int) error {
+	deleted, err := s.repo.DeleteWant(id, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("want not found")
+	}
+	return nil
+}
+
+// matchWants records a suggestion for every other user's want that a newly
+// listed item satisfies: same category or a subcategory of it, every keyword
+// found in the item's text, and within the want's distance of its owner.
+func (s *Service) matchWants(item *models.Item) error {
+	candidates, err := s.repo.GetCandidateWants(item)
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	location, err := s.repo.GetItemLocation(item.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, want := range candidates {
+		if want.MaxDistanceKm != nil {
+			if location == nil || want.OwnerLocation == nil {
+				continue
+			}
+			d := geo.Distance(
+				geo.Point{Lat: want.OwnerLocation.Latitude, Lng: want.OwnerLocation.Longitude},
+				geo.Point{Lat: location.Latitude, Lng: location.Longitude},
+			)
+			if d > *want.MaxDistanceKm {
+				continue
+			}
+		}
+
+		if want.Keywords != "" {
+			matched, err := s.repo.ItemMatchesText(item.ID, buildMatchExpression(want.Keywords))
+			if err != nil {
+				return err
+			}
+			if !matched {
+				continue
+			}
+		}
+
+		if err := s.repo.CreateWantSuggestion(want.ID, item.ID, want.UserID); err != nil {
+			return err
+		}
+		log.Printf("Suggested item %d to user %d for want %d", item.ID, want.UserID, want.ID)
+	}
+
+	return nil
+}
@@ -27,9 +27,9 @@ func (r *Store) CreateUser(user *models.User) error {
 func (r *Store) GetUserByEmail(email string) (*models.User, error) {
 	var user models.User
 	err := r.db.QueryRow(
-		"SELECT id, name, email, password_hash, created_at FROM users WHERE email = ?",
+		"SELECT id, name, email, password_hash, email_verified_at, created_at FROM users WHERE email = ?",
 		email,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.CreatedAt)
+	).Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.EmailVerifiedAt, &user.CreatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -43,10 +43,11 @@ func (r *Store) GetUserByEmail(email string) (*models.User, error) {
 
 func (r *Store) GetUserByID(id int) (*models.User, error) {
 	var user models.User
+	var lat, lng sql.NullFloat64
 	err := r.db.QueryRow(
-		"SELECT id, name, email, created_at FROM users WHERE id = ?",
+		"SELECT id, name, email, latitude, longitude, COALESCE(display_area, ''), interest_hints, email_verified_at, created_at FROM users WHERE id = ?",
 		id,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+	).Scan(&user.ID, &user.Name, &user.Email, &lat, &lng, &user.DisplayArea, &user.InterestHints, &user.EmailVerifiedAt, &user.CreatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -55,5 +56,24 @@ func (r *Store) GetUserByID(id int) (*models.User, error) {
 		return nil, err
 	}
 
+	if lat.Valid && lng.Valid {
+		user.Location = &models.Location{Latitude: lat.Float64, Longitude: lng.Float64}
+	}
 	return &user, nil
-}
\ No newline at end of file
+}
+
+// SetUserLocation stores where a user is. A nil location clears it, along
+// with the display area.
+func (r *Store) SetUserLocation(userID int, location *models.Location, displayArea string) error {
+	_, err := r.db.Exec(
+		"UPDATE users SET latitude = ?, longitude = ?, display_area = NULLIF(?, '') WHERE id = ?",
+		latitude(location), longitude(location), displayArea, userID,
+	)
+	return err
+}
+
+// SetInterestHints turns a user's reciprocal interest hints on or off.
+func (r *Store) SetInterestHints(userID int, enabled bool) error {
+	_, err := r.db.Exec("UPDATE users SET interest_hints = ? WHERE id = ?", enabled, userID)
+	return err
+}
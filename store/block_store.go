@@ -0,0 +1,61 @@
+package store
+
+import (
+	"github.com/notLeoHirano/bartr/models"
+)
+
+// CreateBlock records that blockerID blocked blockedID. Blocking someone
+// twice is a no-op.
+func (r *Store) CreateBlock(blockerID, blockedID int) error {
+	_, err := r.db.Exec(
+		"INSERT OR IGNORE INTO blocks (blocker_id, blocked_id) VALUES (?, ?)",
+		blockerID, blockedID,
+	)
+	return err
+}
+
+func (r *Store) DeleteBlock(blockerID, blockedID int) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM blocks WHERE blocker_id = ? AND blocked_id = ?", blockerID, blockedID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// GetBlocks returns the users blockerID has blocked, most recent first.
+func (r *Store) GetBlocks(blockerID int) ([]models.Block, error) {
+	rows, err := r.db.Query(`
+		SELECT b.blocked_id, u.name, b.created_at
+		FROM blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = ?
+		ORDER BY b.created_at DESC, b.id DESC
+	`, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := []models.Block{}
+	for rows.Next() {
+		var b models.Block
+		if err := rows.Scan(&b.UserID, &b.UserName, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+
+	return blocks, rows.Err()
+}
+
+// IsBlockedBetween reports whether either user has blocked the other.
+func (r *Store) IsBlockedBetween(user1ID, user2ID int) (bool, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM blocks
+		WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+	`, user1ID, user2ID, user2ID, user1ID).Scan(&count)
+	return count > 0, err
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/notLeoHirano/bartr/database"
+	"github.com/notLeoHirano/bartr/models"
+)
+
+// GetCategories returns the whole taxonomy as a flat list in display order.
+func (r *Store) GetCategories() ([]models.Category, error) {
+	rows, err := r.db.Query("SELECT id, slug, name, parent_id FROM categories ORDER BY position, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.ID, &c.Slug, &c.Name, &c.ParentID); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, rows.Err()
+}
+
+// FindCategory looks a category up by slug, display name or one of the
+// taxonomy's aliases, ignoring case. It returns nil when nothing matches.
+func (r *Store) FindCategory(ref string) (*models.Category, error) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	if slug, ok := database.CategorySlug(ref); ok {
+		ref = slug
+	}
+
+	var c models.Category
+	err := r.db.QueryRow(
+		"SELECT id, slug, name, parent_id FROM categories WHERE slug = ? OR LOWER(name) = ?",
+		ref, ref,
+	).Scan(&c.ID, &c.Slug, &c.Name, &c.ParentID)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
@@ -1,6 +1,10 @@
 package store
 
-import "github.com/notLeoHirano/bartr/models"
+import (
+	"time"
+
+	"github.com/notLeoHirano/bartr/models"
+)
 
 func (r *Store) CreateComment(comment *models.Comment) error {
 	result, err := r.db.Exec(
@@ -20,18 +24,27 @@ func (r *Store) CreateComment(comment *models.Comment) error {
 	return nil
 }
 
-func (r *Store) GetComments(matchID int) ([]models.Comment, error) {
-	query := `
+// GetComments returns one page of a match's comments, oldest first, and the
+// cursor of the next page.
+func (r *Store) GetComments(matchID int, page models.PageRequest) ([]models.Comment, string, error) {
+	after, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	q := (&queryBuilder{}).where("c.match_id = ? AND c.archived_at IS NULL", matchID)
+	q.after("c", after, false)
+
+	suffix, suffixArgs := pageSuffix("c", page.Limit, false)
+	query, args := q.build(`
 		SELECT c.id, c.match_id, c.user_id, u.name, c.content, c.created_at
 		FROM comments c
 		JOIN users u ON c.user_id = u.id
-		WHERE c.match_id = ?
-		ORDER BY c.created_at ASC
-	`
+	`, suffix, suffixArgs...)
 
-	rows, err := r.db.Query(query, matchID)
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
@@ -39,10 +52,16 @@ func (r *Store) GetComments(matchID int) ([]models.Comment, error) {
 	for rows.Next() {
 		var c models.Comment
 		if err := rows.Scan(&c.ID, &c.MatchID, &c.UserID, &c.UserName, &c.Content, &c.CreatedAt); err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		comments = append(comments, c)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
 
-	return comments, rows.Err()
+	comments, next := trimPage(comments, page.Limit, func(c models.Comment) (time.Time, int) {
+		return c.CreatedAt, c.ID
+	})
+	return comments, next, nil
 }
\ No newline at end of file
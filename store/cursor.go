@@ -0,0 +1,119 @@
+package store
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqliteTimeLayout is how CURRENT_TIMESTAMP values are stored, which lets
+// cursor timestamps be compared against created_at columns directly.
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// cursor is a keyset position: the created_at and id of the last row a client
+// has seen. Ties on created_at are broken by id so pages never overlap or
+// skip rows, even when many rows share a timestamp. Exclude lists items the
+// client was already shown out of order, which later pages leave out.
+type cursor struct {
+	createdAt string
+	id        int
+	exclude   []int
+}
+
+func encodeCursor(createdAt time.Time, id int, exclude ...int) string {
+	raw := createdAt.UTC().Format(sqliteTimeLayout) + "|" + strconv.Itoa(id)
+	if len(exclude) > 0 {
+		ids := make([]string, len(exclude))
+		for i, v := range exclude {
+			ids[i] = strconv.Itoa(v)
+		}
+		raw += "|" + strings.Join(ids, ",")
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor from a client. An empty string means "start
+// from the beginning" and returns nil.
+func decodeCursor(s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	createdAt, idPart := parts[0], parts[1]
+	if _, err := time.Parse(sqliteTimeLayout, createdAt); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	id, err := strconv.Atoi(idPart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	c := &cursor{createdAt: createdAt, id: id}
+	if len(parts) == 3 {
+		for _, v := range strings.Split(parts[2], ",") {
+			excluded, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cursor")
+			}
+			c.exclude = append(c.exclude, excluded)
+		}
+	}
+	return c, nil
+}
+
+// after restricts q to rows past c in a created_at/id ordering over rows
+// aliased as alias. Descending pages continue with older rows, ascending ones
+// with newer rows. A nil cursor adds nothing.
+func (q *queryBuilder) after(alias string, c *cursor, descending bool) *queryBuilder {
+	if c == nil {
+		return q
+	}
+
+	op := ">"
+	if descending {
+		op = "<"
+	}
+	return q.where(
+		fmt.Sprintf("(%[1]s.created_at %[2]s ? OR (%[1]s.created_at = ? AND %[1]s.id %[2]s ?))", alias, op),
+		c.createdAt, c.createdAt, c.id,
+	)
+}
+
+// pageSuffix returns the ORDER BY and LIMIT that go with after. One extra row
+// is requested so trimPage can tell whether another page exists. A limit of
+// 0 returns every row.
+func pageSuffix(alias string, limit int, descending bool) (string, []interface{}) {
+	dir := "ASC"
+	if descending {
+		dir = "DESC"
+	}
+	suffix := fmt.Sprintf(" ORDER BY %[1]s.created_at %[2]s, %[1]s.id %[2]s", alias, dir)
+
+	if limit <= 0 {
+		return suffix, nil
+	}
+	return suffix + " LIMIT ?", []interface{}{limit + 1}
+}
+
+// trimPage drops the extra row requested by pageSuffix and returns the cursor
+// for the next page, or "" when rows is the last page.
+func trimPage[T any](rows []T, limit int, key func(T) (time.Time, int)) ([]T, string) {
+	if limit <= 0 || len(rows) <= limit {
+		return rows, ""
+	}
+
+	rows = rows[:limit]
+	createdAt, id := key(rows[limit-1])
+	return rows, encodeCursor(createdAt, id)
+}
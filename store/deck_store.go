@@ -0,0 +1,61 @@
+package store
+
+import "database/sql"
+
+// GetLikedCategoryCounts counts userID's right swipes and super likes by the
+// category of the item, and returns the total including uncategorized items.
+func (r *Store) GetLikedCategoryCounts(userID int) (map[int]int, int, error) {
+	rows, err := r.db.Query(`
+		SELECT i.category_id, COUNT(*)
+		FROM swipes s
+		JOIN items i ON i.id = s.item_id
+		WHERE s.user_id = ? AND s.direction IN ('right', 'super')
+		GROUP BY i.category_id
+	`, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	counts := map[int]int{}
+	total := 0
+	for rows.Next() {
+		var categoryID sql.NullInt64
+		var count int
+		if err := rows.Scan(&categoryID, &count); err != nil {
+			return nil, 0, err
+		}
+		if categoryID.Valid {
+			counts[int(categoryID.Int64)] = count
+		}
+		total += count
+	}
+
+	return counts, total, rows.Err()
+}
+
+// GetInterestedOwners returns the users who right swiped or super liked any
+// of userID's items.
+func (r *Store) GetInterestedOwners(userID int) (map[int]bool, error) {
+	rows, err := r.db.Query(`
+		SELECT DISTINCT s.user_id
+		FROM swipes s
+		JOIN items mine ON mine.id = s.item_id
+		WHERE mine.user_id = ? AND s.direction IN ('right', 'super')
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		owners[id] = true
+	}
+
+	return owners, rows.Err()
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/notLeoHirano/bartr/models"
+)
+
+// CreateEmailVerification stores a verification token, retiring any earlier
+// unused ones of the same user so only the newest email works.
+func (r *Store) CreateEmailVerification(v *models.EmailVerification) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"UPDATE email_verifications SET used_at = ? WHERE user_id = ? AND used_at IS NULL",
+		time.Now().UTC().Format(sqliteTimeLayout), v.UserID,
+	); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO email_verifications (user_id, token_hash, expires_at) VALUES (?, ?, ?)",
+		v.UserID, v.TokenHash, v.ExpiresAt.UTC().Format(sqliteTimeLayout),
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	v.ID = int(id)
+	return tx.Commit()
+}
+
+// GetEmailVerification looks a verification token up by its hash.
+func (r *Store) GetEmailVerification(tokenHash string) (*models.EmailVerification, error) {
+	var v models.EmailVerification
+	err := r.db.QueryRow(
+		"SELECT id, user_id, token_hash, expires_at, used_at, created_at FROM email_verifications WHERE token_hash = ?",
+		tokenHash,
+	).Scan(&v.ID, &v.UserID, &v.TokenHash, &v.ExpiresAt, &v.UsedAt, &v.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// VerifyEmail uses up a verification token and marks the user's email as
+// verified in one transaction. It reports false, changing nothing, when the
+// token was already used.
+func (r *Store) VerifyEmail(v *models.EmailVerification) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(sqliteTimeLayout)
+	result, err := tx.Exec(
+		"UPDATE email_verifications SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		now, v.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE users SET email_verified_at = ? WHERE id = ? AND email_verified_at IS NULL",
+		now, v.UserID,
+	); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
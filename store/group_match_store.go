@@ -0,0 +1,260 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/notLeoHirano/bartr/models"
+	"github.com/notLeoHirano/bartr/tradegraph"
+)
+
+// GetTradeEdges returns the right swipes that can still take part in a ring
+// trade: on available items of other users, and not already offered in a
+// proposed group match.
+func (r *Store) GetTradeEdges() ([]tradegraph.Edge, error) {
+	rows, err := r.db.Query(`
+		SELECT s.user_id, i.user_id, i.id
+		FROM swipes s
+		JOIN items i ON i.id = s.item_id
+		WHERE s.direction IN ('right', 'super') AND i.status = ? AND s.user_id != i.user_id
+		AND i.id NOT IN (
+			SELECT p.gives_item_id FROM group_match_participants p
+			JOIN group_matches g ON g.id = p.group_match_id
+			WHERE g.status = ?
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM blocks b
+			WHERE (b.blocker_id = s.user_id AND b.blocked_id = i.user_id)
+			OR (b.blocker_id = i.user_id AND b.blocked_id = s.user_id)
+		)
+		ORDER BY s.id
+	`, models.ItemStatusAvailable, models.MatchStatusProposed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var edges []tradegraph.Edge
+	for rows.Next() {
+		var e tradegraph.Edge
+		if err := rows.Scan(&e.From, &e.To, &e.Item); err != nil {
+			return nil, err
+		}
+		edges = append(edges, e)
+	}
+
+	return edges, rows.Err()
+}
+
+// CreateGroupMatch proposes a ring trade. It returns 0 when the same items
+// were proposed before, whatever became of that proposal, or when any of
+// them is no longer available.
+func (r *Store) CreateGroupMatch(cycle tradegraph.Cycle) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT OR IGNORE INTO group_matches (cycle_key, status) VALUES (?, ?)",
+		cycle.Key(), models.MatchStatusProposed)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, e := range cycle {
+		result, err := tx.Exec(`
+			INSERT INTO group_match_participants (group_match_id, position, user_id, gives_item_id, receives_item_id)
+			SELECT ?, ?, ?, ?, ? WHERE (SELECT COUNT(*) FROM items WHERE id IN (?, ?) AND status = ?) = 2
+		`, id, i, e.From, cycle.Gives(i), e.Item, cycle.Gives(i), e.Item, models.ItemStatusAvailable)
+		if err != nil {
+			return 0, err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return 0, err
+		}
+	}
+
+	return int(id), tx.Commit()
+}
+
+const groupParticipantQuery = `
+	SELECT p.group_match_id, p.user_id, u.name, p.position,
+		p.gives_item_id, gi.title, p.receives_item_id, ri.title, p.response, p.responded_at, p.confirmed_at
+	FROM group_match_participants p
+	JOIN users u ON u.id = p.user_id
+	JOIN items gi ON gi.id = p.gives_item_id
+	JOIN items ri ON ri.id = p.receives_item_id
+`
+
+// GetGroupMatches returns the group matches userID takes part in, newest first.
+func (r *Store) GetGroupMatches(userID int) ([]models.GroupMatch, error) {
+	rows, err := r.db.Query(`
+		SELECT g.id, g.status, g.created_at FROM group_matches g
+		WHERE g.id IN (SELECT group_match_id FROM group_match_participants WHERE user_id = ?)
+		ORDER BY g.created_at DESC, g.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []models.GroupMatch{}
+	index := map[int]int{}
+	for rows.Next() {
+		var g models.GroupMatch
+		if err := rows.Scan(&g.ID, &g.Status, &g.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		g.Participants = []models.GroupMatchParticipant{}
+		index[g.ID] = len(groups)
+		groups = append(groups, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.Query(groupParticipantQuery+`
+		WHERE p.group_match_id IN (SELECT group_match_id FROM group_match_participants WHERE user_id = ?)
+		ORDER BY p.group_match_id, p.position
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupID int
+		p, err := scanParticipant(rows, &groupID)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[groupID]; ok {
+			groups[i].Participants = append(groups[i].Participants, p)
+		}
+	}
+
+	return groups, rows.Err()
+}
+
+func (r *Store) GetGroupMatch(id int) (*models.GroupMatch, error) {
+	var g models.GroupMatch
+	err := r.db.QueryRow("SELECT id, status, created_at FROM group_matches WHERE id = ?", id).
+		Scan(&g.ID, &g.Status, &g.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(groupParticipantQuery+" WHERE p.group_match_id = ? ORDER BY p.position", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	g.Participants = []models.GroupMatchParticipant{}
+	for rows.Next() {
+		var groupID int
+		p, err := scanParticipant(rows, &groupID)
+		if err != nil {
+			return nil, err
+		}
+		g.Participants = append(g.Participants, p)
+	}
+
+	return &g, rows.Err()
+}
+
+func scanParticipant(rows *sql.Rows, groupID *int) (models.GroupMatchParticipant, error) {
+	var p models.GroupMatchParticipant
+	err := rows.Scan(groupID, &p.UserID, &p.UserName, &p.Position,
+		&p.GivesItemID, &p.GivesItemTitle, &p.ReceivesItemID, &p.ReceivesItemTitle, &p.Response, &p.RespondedAt, &p.ConfirmedAt)
+	return p, err
+}
+
+// RecordGroupResponse stores a participant's answer to a proposed group
+// match. It returns false when the group is not proposed or the participant
+// already answered.
+func (r *Store) RecordGroupResponse(groupID, userID int, response string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE group_match_participants SET response = ?, responded_at = ?
+		WHERE group_match_id = ? AND user_id = ? AND response = ?
+		AND (SELECT status FROM group_matches WHERE id = ?) = ?
+	`, response, time.Now().UTC().Format(sqliteTimeLayout), groupID, userID, models.GroupResponsePending,
+		groupID, models.MatchStatusProposed)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ConfirmGroupMatch records that userID confirmed an accepted group match's
+// trade happened. It returns false if the group is not accepted or the user
+// had already confirmed.
+func (r *Store) ConfirmGroupMatch(groupID, userID int) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE group_match_participants SET confirmed_at = CURRENT_TIMESTAMP
+		WHERE group_match_id = ? AND user_id = ? AND confirmed_at IS NULL
+		AND (SELECT status FROM group_matches WHERE id = ?) = ?
+	`, groupID, userID, groupID, models.MatchStatusAccepted)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// TransitionGroupMatch moves a group match from one status to another and,
+// in the same transaction, every item it trades from itemFrom to itemTo.
+// Empty item statuses leave the items alone. Nothing is written and false is
+// returned if the group or any item is not in the expected status.
+func (r *Store) TransitionGroupMatch(groupID int, from, to, itemFrom, itemTo string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE group_matches SET status = ? WHERE id = ? AND status = ?", to, groupID, from)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if itemFrom != "" {
+		var participants int64
+		if err := tx.QueryRow("SELECT COUNT(*) FROM group_match_participants WHERE group_match_id = ?", groupID).
+			Scan(&participants); err != nil {
+			return false, err
+		}
+
+		result, err = tx.Exec(`
+			UPDATE items SET status = ?
+			WHERE status = ? AND id IN (SELECT gives_item_id FROM group_match_participants WHERE group_match_id = ?)
+		`, itemTo, itemFrom, groupID)
+		if err != nil {
+			return false, err
+		}
+		if n, err := result.RowsAffected(); err != nil || n != participants {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
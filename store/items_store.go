@@ -1,40 +1,227 @@
 package store
 
-import "github.com/notLeoHirano/bartr/models"
-func (r *Store) GetItems(userID int, excludeOwn bool) ([]models.ItemWithOwner, error) {
-	query := `
-		SELECT i.id, i.user_id, i.title, i.description, i.category, COALESCE(i.image_url, ''), i.created_at, u.name
-		FROM items i
-		JOIN users u ON i.user_id = u.id
-		WHERE 1=1
-	`
-	args := []interface{}{}
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
 
-	if excludeOwn && userID > 0 {
-		query += " AND i.user_id != ?"
-		args = append(args, userID)
+	"github.com/notLeoHirano/bartr/geo"
+	"github.com/notLeoHirano/bartr/models"
+)
+
+// itemColumns selects an item from rows aliased as i, in scanItem order.
+const itemColumns = `i.id, i.user_id, i.title, COALESCE(i.description, ''), COALESCE(i.category, ''), i.category_id,
+	COALESCE(i.image_url, ''), COALESCE(i.condition, ''), i.estimated_value, COALESCE(i.display_area, ''),
+	i.status, i.created_at`
+
+func scanItem(scanner interface{ Scan(...interface{}) error }, item *models.Item, extra ...interface{}) error {
+	dest := []interface{}{&item.ID, &item.UserID, &item.Title, &item.Description, &item.Category, &item.CategoryID,
+		&item.ImageURL, &item.Condition, &item.EstimatedValue, &item.DisplayArea, &item.Status, &item.CreatedAt}
+	return scanner.Scan(append(dest, extra...)...)
+}
+
+// applyFeedRules restricts q, over items aliased as i, to what userID may see
+// in the feed, then narrows it by filter.
+func applyFeedRules(q *queryBuilder, userID int, filter models.ItemFilter) {
+	// Other people's items only show up while they can still be traded;
+	// owners keep seeing their own items whatever their status.
+	q.where("(i.status = ? OR i.user_id = ?)", models.ItemStatusAvailable, userID)
+
+	if filter.ExcludeOwn && userID > 0 {
+		q.where("i.user_id != ?", userID)
 	}
 
 	if userID > 0 {
-		query += ` AND i.id NOT IN (
-			SELECT item_id FROM swipes WHERE user_id = ?
-		)`
-		args = append(args, userID)
+		if filter.ResurfaceBefore != nil {
+			q.where("i.id NOT IN (SELECT item_id FROM swipes WHERE user_id = ? AND (direction != 'left' OR created_at >= ?))",
+				userID, filter.ResurfaceBefore.UTC().Format(sqliteTimeLayout))
+		} else {
+			q.where("i.id NOT IN (SELECT item_id FROM swipes WHERE user_id = ?)", userID)
+		}
+		q.where("i.user_id NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = ?)", userID)
+	}
+
+	applyItemFilter(q, filter)
+}
+
+// applyItemFilter adds the user-selected feed filters. Every field is
+// optional and filters combine with AND; values inside a list combine with OR.
+func applyItemFilter(q *queryBuilder, filter models.ItemFilter) {
+	if len(filter.Categories) > 0 {
+		refs := make([]interface{}, len(filter.Categories))
+		for i, c := range filter.Categories {
+			refs[i] = strings.ToLower(strings.TrimSpace(c))
+		}
+		in := placeholders(len(refs))
+		// Selecting a category also selects everything below it
+		q.where(`i.category_id IN (
+			WITH RECURSIVE tree(id) AS (
+				SELECT id FROM categories WHERE slug IN (`+in+`) OR LOWER(name) IN (`+in+`)
+				UNION
+				SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+			)
+			SELECT id FROM tree
+		)`, append(refs, refs...)...)
+	}
+	q.whereIn("i.condition", values(filter.Conditions))
+
+	if filter.MinValue != nil {
+		q.where("i.estimated_value >= ?", *filter.MinValue)
+	}
+	if filter.MaxValue != nil {
+		q.where("i.estimated_value <= ?", *filter.MaxValue)
+	}
+	if filter.PostedSince != nil {
+		q.where("i.created_at >= ?", filter.PostedSince.UTC().Format(sqliteTimeLayout))
 	}
 
-	query += " ORDER BY i.created_at DESC"
+	q.whereNotIn("i.user_id", values(filter.ExcludeOwners))
+	q.whereNotIn("i.id", values(filter.ExcludeItems))
 
+	// The bounding box only narrows the candidates; nearby does the cut on the
+	// rounded distance, which can be up to geo.MaxRoundingKm short of the real one
+	if filter.Near != nil && filter.RadiusKm != nil {
+		box := geo.BoundingBox(geo.Point{Lat: filter.Near.Latitude, Lng: filter.Near.Longitude}, *filter.RadiusKm+geo.MaxRoundingKm)
+		q.where("COALESCE(i.latitude, u.latitude) BETWEEN ? AND ? AND COALESCE(i.longitude, u.longitude) BETWEEN ? AND ?",
+			box.MinLat, box.MaxLat, box.MinLng, box.MaxLng)
+	}
+}
+
+// feedLocationColumns locate a feed row for nearby, over items i joined with
+// their owner u. Items without a position of their own are where their owner is.
+const feedLocationColumns = `COALESCE(i.latitude, u.latitude), COALESCE(i.longitude, u.longitude), COALESCE(u.display_area, '')`
+
+// itemLocation receives feedLocationColumns.
+type itemLocation struct {
+	lat, lng  sql.NullFloat64
+	ownerArea string
+}
+
+func (l *itemLocation) dest() []interface{} {
+	return []interface{}{&l.lat, &l.lng, &l.ownerArea}
+}
+
+// nearby fills in item's display area and its rounded distance from
+// filter.Near, and reports false when that distance exceeds filter.RadiusKm.
+// The radius is checked against the rounded distance rather than the exact
+// one, so narrowing it step by step reveals no more than distance_km already
+// does. Items without a known position are only kept when there is no radius.
+func nearby(item *models.ItemWithOwner, loc itemLocation, filter models.ItemFilter) bool {
+	if item.DisplayArea == "" {
+		item.DisplayArea = loc.ownerArea
+	}
+	if filter.Near == nil || !loc.lat.Valid || !loc.lng.Valid {
+		return filter.RadiusKm == nil
+	}
+
+	d := geo.RoundDistance(geo.Distance(
+		geo.Point{Lat: filter.Near.Latitude, Lng: filter.Near.Longitude},
+		geo.Point{Lat: loc.lat.Float64, Lng: loc.lng.Float64},
+	))
+	if filter.RadiusKm != nil && d > *filter.RadiusKm {
+		return false
+	}
+	item.DistanceKm = &d
+	return true
+}
+
+// GetItems returns one page of the feed, newest first, and the cursor of the
+// next page. With a radius filter, rows that pass the bounding box but not
+// the distance check are skipped and further rows are read until the
+// page is full. filter.ExcludeItems is carried in the cursor to later pages.
+func (r *Store) GetItems(userID int, filter models.ItemFilter, page models.PageRequest) ([]models.ItemWithOwner, string, error) {
+	after, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	if after != nil {
+		filter.ExcludeItems = append(filter.ExcludeItems, after.exclude...)
+	}
+
+	items := []models.ItemWithOwner{}
+	for {
+		q := &queryBuilder{}
+		applyFeedRules(q, userID, filter)
+		q.after("i", after, true)
+
+		suffix, suffixArgs := pageSuffix("i", page.Limit, true)
+		query, args := q.build(`
+			SELECT `+itemColumns+`, u.name, `+feedLocationColumns+`
+			FROM items i
+			JOIN users u ON i.user_id = u.id
+		`, suffix, suffixArgs...)
+
+		batch, scanned, last, err := r.queryFeed(query, args, filter)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, batch...)
+
+		// A short read means the feed is exhausted
+		if page.Limit <= 0 || scanned <= page.Limit || len(items) > page.Limit {
+			break
+		}
+		after = last
+	}
+
+	items, next := trimPage(items, page.Limit, func(item models.ItemWithOwner) (time.Time, int) {
+		return item.CreatedAt, item.ID
+	})
+	if next != "" && len(filter.ExcludeItems) > 0 {
+		last := items[len(items)-1]
+		next = encodeCursor(last.CreatedAt, last.ID, filter.ExcludeItems...)
+	}
+	return items, next, nil
+}
+
+// queryFeed runs a GetItems query and keeps the rows that pass nearby. It also
+// reports how many rows were read and the position of the last one.
+func (r *Store) queryFeed(query string, args []interface{}, filter models.ItemFilter) ([]models.ItemWithOwner, int, *cursor, error) {
 	rows, err := r.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, nil, err
 	}
 	defer rows.Close()
 
-	items := []models.ItemWithOwner{}
+	var (
+		items   []models.ItemWithOwner
+		scanned int
+		last    *cursor
+	)
 	for rows.Next() {
 		var item models.ItemWithOwner
-		if err := rows.Scan(&item.ID, &item.UserID, &item.Title, &item.Description,
-			&item.Category, &item.ImageURL, &item.CreatedAt, &item.OwnerName); err != nil {
+		var loc itemLocation
+		if err := scanItem(rows, &item.Item, append([]interface{}{&item.OwnerName}, loc.dest()...)...); err != nil {
+			return nil, 0, nil, err
+		}
+		scanned++
+		last = &cursor{createdAt: item.CreatedAt.UTC().Format(sqliteTimeLayout), id: item.ID}
+
+		if nearby(&item, loc, filter) {
+			items = append(items, item)
+		}
+	}
+
+	return items, scanned, last, rows.Err()
+}
+
+// GetUserItems returns every item owned by userID, whatever its status.
+func (r *Store) GetUserItems(userID int) ([]models.Item, error) {
+	rows, err := r.db.Query(`
+		SELECT `+itemColumns+` FROM items i
+		WHERE i.user_id = ?
+		ORDER BY i.created_at DESC, i.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.Item{}
+	for rows.Next() {
+		var item models.Item
+		if err := scanItem(rows, &item); err != nil {
 			return nil, err
 		}
 		items = append(items, item)
@@ -45,8 +232,10 @@ func (r *Store) GetItems(userID int, excludeOwn bool) ([]models.ItemWithOwner, e
 
 func (r *Store) CreateItem(item *models.Item) error {
 	result, err := r.db.Exec(
-		"INSERT INTO items (user_id, title, description, category, image_url) VALUES (?, ?, ?, ?, ?)",
-		item.UserID, item.Title, item.Description, item.Category, item.ImageURL,
+		`INSERT INTO items (user_id, title, description, category, category_id, image_url, condition, estimated_value,
+			latitude, longitude, display_area, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		item.UserID, item.Title, item.Description, item.Category, item.CategoryID, item.ImageURL, item.Condition, item.EstimatedValue,
+		latitude(item.Location), longitude(item.Location), item.DisplayArea, item.Status,
 	)
 	if err != nil {
 		return err
@@ -80,3 +269,204 @@ func (r *Store) GetItemOwnerID(itemID int) (int, error) {
 	err := r.db.QueryRow("SELECT user_id FROM items WHERE id = ?", itemID).Scan(&ownerID)
 	return ownerID, err
 }
+
+func (r *Store) GetItem(id int) (*models.Item, error) {
+	var item models.Item
+	err := scanItem(r.db.QueryRow("SELECT "+itemColumns+" FROM items i WHERE i.id = ?", id), &item)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// UpdateItem applies the non-nil fields of update to an item owned by userID and
+// records one item_revisions row per field that actually changed. It returns nil
+// when the item does not exist or belongs to someone else.
+func (r *Store) UpdateItem(id int, userID int, update models.UpdateItemRequest) (*models.Item, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var item models.Item
+	err = scanItem(tx.QueryRow("SELECT "+itemColumns+" FROM items i WHERE i.id = ? AND i.user_id = ?", id, userID), &item)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var lat, lng sql.NullFloat64
+	if err := tx.QueryRow("SELECT latitude, longitude FROM items WHERE id = ?", id).Scan(&lat, &lng); err != nil {
+		return nil, err
+	}
+	if lat.Valid && lng.Valid {
+		item.Location = &models.Location{Latitude: lat.Float64, Longitude: lng.Float64}
+	}
+
+	fields := []struct {
+		name    string
+		current *string
+		value   *string
+	}{
+		{"title", &item.Title, update.Title},
+		{"description", &item.Description, update.Description},
+		{"category", &item.Category, update.Category},
+		{"image_url", &item.ImageURL, update.ImageURL},
+		{"condition", &item.Condition, update.Condition},
+		{"display_area", &item.DisplayArea, update.DisplayArea},
+	}
+
+	for _, f := range fields {
+		if f.value == nil || *f.value == *f.current {
+			continue
+		}
+
+		if _, err := tx.Exec(
+			"INSERT INTO item_revisions (item_id, user_id, field, old_value, new_value) VALUES (?, ?, ?, ?, ?)",
+			id, userID, f.name, *f.current, *f.value,
+		); err != nil {
+			return nil, err
+		}
+		*f.current = *f.value
+	}
+
+	if update.EstimatedValue.Set && !equalInts(update.EstimatedValue.Value, item.EstimatedValue) {
+		if _, err := tx.Exec(
+			"INSERT INTO item_revisions (item_id, user_id, field, old_value, new_value) VALUES (?, ?, ?, ?, ?)",
+			id, userID, "estimated_value", formatInt(item.EstimatedValue), formatInt(update.EstimatedValue.Value),
+		); err != nil {
+			return nil, err
+		}
+		item.EstimatedValue = update.EstimatedValue.Value
+	}
+
+	// Revisions are visible to other users, who never see exact coordinates,
+	// so a location change is only recorded as having happened
+	if update.Location.Set && !equalLocations(update.Location.Value, item.Location) {
+		if _, err := tx.Exec(
+			"INSERT INTO item_revisions (item_id, user_id, field, old_value, new_value) VALUES (?, ?, ?, ?, ?)",
+			id, userID, "location", locationRevision(item.Location), locationRevision(update.Location.Value),
+		); err != nil {
+			return nil, err
+		}
+		item.Location = update.Location.Value
+	}
+
+	if update.Category != nil {
+		item.CategoryID = update.CategoryID
+	}
+
+	_, err = tx.Exec(
+		`UPDATE items SET title = ?, description = ?, category = ?, category_id = ?, image_url = ?, condition = ?, estimated_value = ?,
+			latitude = ?, longitude = ?, display_area = ? WHERE id = ?`,
+		item.Title, item.Description, item.Category, item.CategoryID, item.ImageURL, item.Condition, item.EstimatedValue,
+		latitude(item.Location), longitude(item.Location), item.DisplayArea, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// SetItemStatus moves an item owned by userID from one status to another. It
+// reports false when the item is missing, not owned by userID, or no longer in
+// the expected status.
+func (r *Store) SetItemStatus(id int, userID int, from, to string) (bool, error) {
+	result, err := r.db.Exec(
+		"UPDATE items SET status = ? WHERE id = ? AND user_id = ? AND status = ?",
+		to, id, userID, from,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (r *Store) GetItemRevisions(itemID int) ([]models.ItemRevision, error) {
+	rows, err := r.db.Query(`
+		SELECT id, item_id, user_id, field, COALESCE(old_value, ''), COALESCE(new_value, ''), created_at
+		FROM item_revisions
+		WHERE item_id = ?
+		ORDER BY created_at ASC, id ASC
+	`, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.ItemRevision{}
+	for rows.Next() {
+		var rev models.ItemRevision
+		if err := rows.Scan(&rev.ID, &rev.ItemID, &rev.UserID, &rev.Field,
+			&rev.OldValue, &rev.NewValue, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+func latitude(l *models.Location) interface{} {
+	if l == nil {
+		return nil
+	}
+	return l.Latitude
+}
+
+func longitude(l *models.Location) interface{} {
+	if l == nil {
+		return nil
+	}
+	return l.Longitude
+}
+
+func equalInts(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// formatInt renders an optional number for item_revisions, with "" for none.
+func formatInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func equalLocations(a, b *models.Location) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// locationRevision stands in for a location in item_revisions: "set" when the
+// item has a position of its own and "" when it is at its owner's.
+func locationRevision(l *models.Location) string {
+	if l == nil {
+		return ""
+	}
+	return "set"
+}
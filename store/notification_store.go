@@ -0,0 +1,108 @@
+package store
+
+import (
+	"time"
+
+	"github.com/notLeoHirano/bartr/models"
+)
+
+func (r *Store) CreateNotification(n *models.Notification) error {
+	result, err := r.db.Exec(
+		"INSERT INTO notifications (user_id, type, actor_id, item_id) VALUES (?, ?, ?, ?)",
+		n.UserID, n.Type, n.ActorID, n.ItemID,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	n.ID = int(id)
+	return nil
+}
+
+// GetNotifications returns one page of userID's notifications, newest first,
+// and the cursor of the next page. Notifications about a user either side
+// has since blocked are left out.
+func (r *Store) GetNotifications(userID int, page models.PageRequest) ([]models.Notification, string, error) {
+	after, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	q := (&queryBuilder{}).where("n.user_id = ?", userID)
+	q.where(`NOT EXISTS (
+		SELECT 1 FROM blocks b
+		WHERE (b.blocker_id = n.user_id AND b.blocked_id = n.actor_id)
+		OR (b.blocker_id = n.actor_id AND b.blocked_id = n.user_id)
+	)`)
+	q.after("n", after, true)
+
+	suffix, suffixArgs := pageSuffix("n", page.Limit, true)
+	query, args := q.build(`
+		SELECT n.id, n.user_id, n.type, n.actor_id, u.name, n.item_id, i.title, n.read_at, n.created_at
+		FROM notifications n
+		JOIN users u ON u.id = n.actor_id
+		JOIN items i ON i.id = n.item_id
+	`, suffix, suffixArgs...)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.ActorID, &n.ActorName,
+			&n.ItemID, &n.ItemTitle, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, "", err
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	notifications, next := trimPage(notifications, page.Limit, func(n models.Notification) (time.Time, int) {
+		return n.CreatedAt, n.ID
+	})
+	return notifications, next, nil
+}
+
+// MarkNotificationsRead marks all of userID's unread notifications as read.
+func (r *Store) MarkNotificationsRead(userID int) error {
+	_, err := r.db.Exec(
+		"UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE user_id = ? AND read_at IS NULL",
+		userID,
+	)
+	return err
+}
+
+// UseSuperLike spends one of userID's super likes for day, unless quota are
+// already used. It reports whether one was spent.
+func (r *Store) UseSuperLike(userID int, day string, quota int) (bool, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO super_like_usage (user_id, day, used) VALUES (?, ?, 1)
+		ON CONFLICT (user_id, day) DO UPDATE SET used = used + 1 WHERE used < ?
+	`, userID, day, quota)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// RefundSuperLike gives back a super like spent on a swipe that was not made.
+func (r *Store) RefundSuperLike(userID int, day string) error {
+	_, err := r.db.Exec(
+		"UPDATE super_like_usage SET used = used - 1 WHERE user_id = ? AND day = ? AND used > 0",
+		userID, day,
+	)
+	return err
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/notLeoHirano/bartr/models"
+)
+
+// CreatePasswordReset stores a reset token, retiring any earlier unused
+// ones of the same user so only the newest email works.
+func (r *Store) CreatePasswordReset(reset *models.PasswordReset) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL",
+		time.Now().UTC().Format(sqliteTimeLayout), reset.UserID,
+	); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO password_resets (user_id, token_hash, expires_at) VALUES (?, ?, ?)",
+		reset.UserID, reset.TokenHash, reset.ExpiresAt.UTC().Format(sqliteTimeLayout),
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	reset.ID = int(id)
+	return tx.Commit()
+}
+
+// GetPasswordReset looks a reset token up by its hash.
+func (r *Store) GetPasswordReset(tokenHash string) (*models.PasswordReset, error) {
+	var reset models.PasswordReset
+	err := r.db.QueryRow(
+		"SELECT id, user_id, token_hash, expires_at, used_at, created_at FROM password_resets WHERE token_hash = ?",
+		tokenHash,
+	).Scan(&reset.ID, &reset.UserID, &reset.TokenHash, &reset.ExpiresAt, &reset.UsedAt, &reset.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+// ResetPassword uses up a reset token and sets the user's new password hash
+// in one transaction. It reports false, changing nothing, when the token was
+// already used.
+func (r *Store) ResetPassword(reset *models.PasswordReset, passwordHash string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"UPDATE password_resets SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		time.Now().UTC().Format(sqliteTimeLayout), reset.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if _, err := tx.Exec("UPDATE users SET password_hash = ? WHERE id = ?", passwordHash, reset.UserID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,239 @@
+package store
+
+import (
+	"database/sql"
+
+	"github.com/notLeoHirano/bartr/models"
+)
+
+const photoColumns = "id, item_id, position, blob_key, content_type, width, height, size_bytes, created_at"
+
+func scanPhoto(scanner interface{ Scan(...interface{}) error }, p *models.ItemPhoto) error {
+	return scanner.Scan(&p.ID, &p.ItemID, &p.Position, &p.BlobKey, &p.ContentType,
+		&p.Width, &p.Height, &p.SizeBytes, &p.CreatedAt)
+}
+
+// CreateItemPhoto appends a photo after the item's existing photos.
+func (r *Store) CreateItemPhoto(photo *models.ItemPhoto) error {
+	result, err := r.db.Exec(`
+		INSERT INTO item_photos (item_id, position, blob_key, content_type, width, height, size_bytes)
+		VALUES (?, (SELECT COALESCE(MAX(position) + 1, 0) FROM item_photos WHERE item_id = ?), ?, ?, ?, ?, ?)
+	`, photo.ItemID, photo.ItemID, photo.BlobKey, photo.ContentType, photo.Width, photo.Height, photo.SizeBytes)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	return scanPhoto(r.db.QueryRow("SELECT "+photoColumns+" FROM item_photos WHERE id = ?", id), photo)
+}
+
+func (r *Store) GetItemPhoto(id int) (*models.ItemPhoto, error) {
+	var photo models.ItemPhoto
+	err := scanPhoto(r.db.QueryRow("SELECT "+photoColumns+" FROM item_photos WHERE id = ?", id), &photo)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &photo, nil
+}
+
+func (r *Store) GetItemPhotos(itemID int) ([]models.ItemPhoto, error) {
+	photos, err := r.GetPhotosForItems([]int{itemID})
+	if err != nil {
+		return nil, err
+	}
+	if photos[itemID] == nil {
+		return []models.ItemPhoto{}, nil
+	}
+	return photos[itemID], nil
+}
+
+// GetPhotosForItems loads the photos of several items in one query, keyed by item ID.
+func (r *Store) GetPhotosForItems(itemIDs []int) (map[int][]models.ItemPhoto, error) {
+	photos := map[int][]models.ItemPhoto{}
+	if len(itemIDs) == 0 {
+		return photos, nil
+	}
+
+	q := (&queryBuilder{}).whereIn("item_id", values(itemIDs))
+	query, args := q.build("SELECT "+photoColumns+" FROM item_photos", " ORDER BY item_id, position")
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.ItemPhoto
+		if err := scanPhoto(rows, &p); err != nil {
+			return nil, err
+		}
+		photos[p.ItemID] = append(photos[p.ItemID], p)
+	}
+
+	return photos, rows.Err()
+}
+
+func (r *Store) DeleteItemPhoto(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM photo_variants WHERE photo_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM item_photos WHERE id = ?", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Store) DeleteItemPhotos(itemID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"DELETE FROM photo_variants WHERE photo_id IN (SELECT id FROM item_photos WHERE item_id = ?)", itemID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM item_photos WHERE item_id = ?", itemID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReorderItemPhotos sets each photo's position to its index in photoIDs. The
+// caller must pass exactly the item's photo IDs.
+func (r *Store) ReorderItemPhotos(itemID int, photoIDs []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for position, id := range photoIDs {
+		if _, err := tx.Exec(
+			"UPDATE item_photos SET position = ? WHERE id = ? AND item_id = ?",
+			position, id, itemID,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const variantColumns = "id, photo_id, name, blob_key, content_type, width, height, size_bytes"
+
+func scanVariant(scanner interface{ Scan(...interface{}) error }, v *models.PhotoVariant) error {
+	return scanner.Scan(&v.ID, &v.PhotoID, &v.Name, &v.BlobKey, &v.ContentType, &v.Width, &v.Height, &v.SizeBytes)
+}
+
+func (r *Store) CreatePhotoVariant(variant *models.PhotoVariant) error {
+	result, err := r.db.Exec(`
+		INSERT INTO photo_variants (photo_id, name, blob_key, content_type, width, height, size_bytes)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, variant.PhotoID, variant.Name, variant.BlobKey, variant.ContentType, variant.Width, variant.Height, variant.SizeBytes)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	variant.ID = int(id)
+	return nil
+}
+
+func (r *Store) GetPhotoVariant(photoID int, name string) (*models.PhotoVariant, error) {
+	var v models.PhotoVariant
+	err := scanVariant(r.db.QueryRow(
+		"SELECT "+variantColumns+" FROM photo_variants WHERE photo_id = ? AND name = ?",
+		photoID, name,
+	), &v)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// GetVariantsForPhotos loads the variants of several photos, keyed by photo ID.
+func (r *Store) GetVariantsForPhotos(photoIDs []int) (map[int][]models.PhotoVariant, error) {
+	variants := map[int][]models.PhotoVariant{}
+	if len(photoIDs) == 0 {
+		return variants, nil
+	}
+
+	q := (&queryBuilder{}).whereIn("photo_id", values(photoIDs))
+	query, args := q.build("SELECT "+variantColumns+" FROM photo_variants", " ORDER BY photo_id, name")
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v models.PhotoVariant
+		if err := scanVariant(rows, &v); err != nil {
+			return nil, err
+		}
+		variants[v.PhotoID] = append(variants[v.PhotoID], v)
+	}
+
+	return variants, rows.Err()
+}
+
+func (r *Store) DeletePhotoVariants(photoID int) error {
+	_, err := r.db.Exec("DELETE FROM photo_variants WHERE photo_id = ?", photoID)
+	return err
+}
+
+// GetPhotosWithoutVariants returns photos uploaded before variants existed,
+// or whose variant generation failed.
+func (r *Store) GetPhotosWithoutVariants() ([]models.ItemPhoto, error) {
+	rows, err := r.db.Query(`
+		SELECT ` + photoColumns + ` FROM item_photos
+		WHERE id NOT IN (SELECT photo_id FROM photo_variants)
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	photos := []models.ItemPhoto{}
+	for rows.Next() {
+		var p models.ItemPhoto
+		if err := scanPhoto(rows, &p); err != nil {
+			return nil, err
+		}
+		photos = append(photos, p)
+	}
+
+	return photos, rows.Err()
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"strings"
+)
+
+// queryBuilder collects WHERE conditions together with their arguments, so
+// list queries are assembled from parameterized fragments and user input
+// never ends up inside the SQL text.
+type queryBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+// where adds a condition; its placeholders are bound to args in order.
+func (q *queryBuilder) where(condition string, args ...interface{}) *queryBuilder {
+	q.conditions = append(q.conditions, condition)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// whereIn adds "expr IN (?, ...)" with one placeholder per value. An empty
+// list adds nothing, meaning "no restriction".
+func (q *queryBuilder) whereIn(expr string, values []interface{}) *queryBuilder {
+	if len(values) == 0 {
+		return q
+	}
+	return q.where(expr+" IN ("+placeholders(len(values))+")", values...)
+}
+
+// whereNotIn adds "expr NOT IN (?, ...)". An empty list adds nothing.
+func (q *queryBuilder) whereNotIn(expr string, values []interface{}) *queryBuilder {
+	if len(values) == 0 {
+		return q
+	}
+	return q.where(expr+" NOT IN ("+placeholders(len(values))+")", values...)
+}
+
+// build joins base (SELECT ... FROM ... JOIN ...), the collected conditions
+// and suffix (ORDER BY / LIMIT) into one query. suffixArgs bind placeholders
+// in suffix.
+func (q *queryBuilder) build(base, suffix string, suffixArgs ...interface{}) (string, []interface{}) {
+	query := base
+	if len(q.conditions) > 0 {
+		query += " WHERE " + strings.Join(q.conditions, " AND ")
+	}
+	query += suffix
+
+	args := append([]interface{}{}, q.args...)
+	return query, append(args, suffixArgs...)
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// values converts a typed slice for use with whereIn and whereNotIn.
+func values[T any](in []T) []interface{} {
+	out := make([]interface{}, len(in))
+	for i, v := range in {
+		out[i] = v
+	}
+	return out
+}
+
+// nullString binds an empty string as NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+package store
+
+import "github.com/notLeoHirano/bartr/models"
+
+// Snippet delimiters wrapped around matched terms. They are control
+// characters so they cannot collide with item text, and callers replace them
+// with real markup after escaping the snippet.
+const (
+	SnippetMatchStart = "\x02"
+	SnippetMatchEnd   = "\x03"
+)
+
+// SearchItems runs an FTS5 match expression against item titles, descriptions
+// and categories, applying the same visibility rules and filters as GetItems.
+// Results are ordered by bm25 relevance with title hits weighted above
+// category and description hits.
+func (r *Store) SearchItems(userID int, filter models.ItemFilter, match string, limit int) ([]models.ItemSearchResult, error) {
+	q := (&queryBuilder{}).where("items_fts MATCH ?", match)
+	applyFeedRules(q, userID, filter)
+
+	results := []models.ItemSearchResult{}
+	for offset := 0; ; offset += limit {
+		// char(2) and char(3) are SnippetMatchStart and SnippetMatchEnd
+		query, args := q.build(`
+			SELECT `+itemColumns+`, u.name,
+				snippet(items_fts, -1, char(2), char(3), '…', 12),
+				bm25(items_fts, 10.0, 2.0, 5.0) AS rank,
+				`+feedLocationColumns+`
+			FROM items_fts
+			JOIN items i ON i.id = items_fts.rowid
+			JOIN users u ON i.user_id = u.id
+		`, " ORDER BY rank, i.id DESC LIMIT ? OFFSET ?", limit, offset)
+
+		batch, scanned, err := r.querySearch(query, args, filter)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, batch...)
+
+		// Only a radius filter drops rows, so only then can a page need topping up
+		if filter.RadiusKm == nil || scanned < limit || len(results) >= limit {
+			break
+		}
+	}
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (r *Store) querySearch(query string, args []interface{}, filter models.ItemFilter) ([]models.ItemSearchResult, int, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []models.ItemSearchResult
+	scanned := 0
+	for rows.Next() {
+		var res models.ItemSearchResult
+		var loc itemLocation
+		extra := append([]interface{}{&res.OwnerName, &res.Snippet, &res.Rank}, loc.dest()...)
+		if err := scanItem(rows, &res.Item, extra...); err != nil {
+			return nil, 0, err
+		}
+		scanned++
+
+		if nearby(&res.ItemWithOwner, loc, filter) {
+			results = append(results, res)
+		}
+	}
+
+	return results, scanned, rows.Err()
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"time"
+
+	"github.com/notLeoHirano/bartr/models"
+)
+
+// CreateSession stores a new session together with its first refresh token.
+func (r *Store) CreateSession(session *models.Session, token *models.RefreshToken) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"INSERT INTO sessions (id, user_id, user_agent, ip_address) VALUES (?, ?, ?, ?)",
+		session.ID, session.UserID, session.UserAgent, session.IPAddress,
+	); err != nil {
+		return err
+	}
+
+	token.FamilyID = session.ID
+	if err := createRefreshToken(tx, token); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// TouchSession records that sessionID of userID was just used. It reports
+// false when there is no such session or it was revoked.
+func (r *Store) TouchSession(sessionID string, userID int) (bool, error) {
+	result, err := r.db.Exec(
+		"UPDATE sessions SET last_seen_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		time.Now().UTC().Format(sqliteTimeLayout), sessionID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// UpdateSessionDevice records the device a session was last refreshed from.
+func (r *Store) UpdateSessionDevice(sessionID, userAgent, ipAddress string) error {
+	_, err := r.db.Exec(
+		"UPDATE sessions SET user_agent = ?, ip_address = ?, last_seen_at = ? WHERE id = ?",
+		userAgent, ipAddress, time.Now().UTC().Format(sqliteTimeLayout), sessionID,
+	)
+	return err
+}
+
+// GetSessions returns userID's sessions that can still be used: not revoked
+// and holding an unexpired refresh token. Most recently seen first.
+func (r *Store) GetSessions(userID int) ([]models.Session, error) {
+	rows, err := r.db.Query(`
+		SELECT s.id, s.user_id, s.user_agent, s.ip_address, s.created_at, s.last_seen_at
+		FROM sessions s
+		WHERE s.user_id = ? AND s.revoked_at IS NULL
+		AND EXISTS (
+			SELECT 1 FROM refresh_tokens t
+			WHERE t.family_id = s.id AND t.used_at IS NULL AND t.revoked_at IS NULL AND t.expires_at > ?
+		)
+		ORDER BY s.last_seen_at DESC, s.created_at DESC
+	`, userID, time.Now().UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSessions revokes userID's sessions with the given ids, or all of
+// them except keepID when ids is empty, along with their refresh tokens. It
+// returns the ids of the sessions it revoked.
+func (r *Store) RevokeSessions(userID int, ids []string, keepID string) ([]string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	q := &queryBuilder{}
+	q.where("user_id = ?", userID).where("revoked_at IS NULL")
+	if len(ids) > 0 {
+		q.whereIn("id", values(ids))
+	} else {
+		q.where("id != ?", keepID)
+	}
+
+	query, args := q.build("SELECT id FROM sessions", "")
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	revoked := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		revoked = append(revoked, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(revoked) == 0 {
+		return revoked, nil
+	}
+
+	now := time.Now().UTC().Format(sqliteTimeLayout)
+	in := placeholders(len(revoked))
+	args = append([]interface{}{now}, values(revoked)...)
+	if _, err := tx.Exec("UPDATE sessions SET revoked_at = ? WHERE id IN ("+in+")", args...); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE revoked_at IS NULL AND family_id IN ("+in+")", args...); err != nil {
+		return nil, err
+	}
+
+	return revoked, tx.Commit()
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"github.com/notLeoHirano/bartr/models"
+	"github.com/notLeoHirano/bartr/ranking"
+)
+
+// GetLikesByUser returns the items each user right swiped or super liked,
+// keeping each user's maxPerUser most recent likes.
+func (r *Store) GetLikesByUser(maxPerUser int) (map[int][]int, error) {
+	rows, err := r.db.Query(`
+		SELECT user_id, item_id FROM swipes
+		WHERE direction IN ('right', 'super')
+		ORDER BY user_id, created_at DESC, id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	likes := map[int][]int{}
+	for rows.Next() {
+		var userID, itemID int
+		if err := rows.Scan(&userID, &itemID); err != nil {
+			return nil, err
+		}
+		if len(likes[userID]) < maxPerUser {
+			likes[userID] = append(likes[userID], itemID)
+		}
+	}
+
+	return likes, rows.Err()
+}
+
+// ReplaceItemSimilarities swaps the stored similarity model for a new one.
+func (r *Store) ReplaceItemSimilarities(neighbours map[int][]ranking.Neighbour) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM item_similarities"); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO item_similarities (item_id, similar_item_id, score) VALUES (?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for itemID, list := range neighbours {
+		for _, n := range list {
+			if _, err := stmt.Exec(itemID, n.ItemID, n.Score); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetSimilarItems returns up to limit available items most similar to
+// itemID, leaving out userID's own items and those of users they blocked.
+func (r *Store) GetSimilarItems(itemID, userID int, filter models.ItemFilter, limit int) ([]models.ItemWithOwner, error) {
+	q := (&queryBuilder{}).where("sim.item_id = ?", itemID)
+	q.where("i.status = ?", models.ItemStatusAvailable)
+	q.where("i.user_id != ?", userID)
+	q.where("i.user_id NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = ?)", userID)
+
+	query, args := q.build(`
+		SELECT `+itemColumns+`, u.name, `+feedLocationColumns+`, sim.score
+		FROM item_similarities sim
+		JOIN items i ON i.id = sim.similar_item_id
+		JOIN users u ON i.user_id = u.id
+	`, " ORDER BY sim.score DESC, i.id LIMIT ?", limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ItemWithOwner{}
+	for rows.Next() {
+		var item models.ItemWithOwner
+		var loc itemLocation
+
+		dest := append([]interface{}{&item.OwnerName}, loc.dest()...)
+		if err := scanItem(rows, &item.Item, append(dest, &item.Similarity)...); err != nil {
+			return nil, err
+		}
+		nearby(&item, loc, filter)
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// GetLikedNeighbourScores returns, for every item similar to something
+// userID liked, its best similarity score.
+func (r *Store) GetLikedNeighbourScores(userID int) (map[int]float64, error) {
+	rows, err := r.db.Query(`
+		SELECT sim.similar_item_id, MAX(sim.score)
+		FROM item_similarities sim
+		JOIN swipes s ON s.item_id = sim.item_id
+		WHERE s.user_id = ? AND s.direction IN ('right', 'super')
+		GROUP BY sim.similar_item_id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scores := map[int]float64{}
+	for rows.Next() {
+		var itemID int
+		var score float64
+		if err := rows.Scan(&itemID, &score); err != nil {
+			return nil, err
+		}
+		scores[itemID] = score
+	}
+
+	return scores, rows.Err()
+}
@@ -1,17 +1,227 @@
 package store
 
 import (
+	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/notLeoHirano/bartr/models"
 )
 
-func (r *Store) CreateSwipe(swipe *models.Swipe) error {
+// CreateSwipe records a swipe. It returns false without writing anything if
+// the user already swiped on the item or already used the idempotency key.
+func (r *Store) CreateSwipe(swipe *models.Swipe) (bool, error) {
+	result, err := r.db.Exec(
+		"INSERT INTO swipes (user_id, item_id, direction, idempotency_key) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING",
+		swipe.UserID, swipe.ItemID, swipe.Direction, nullString(swipe.IdempotencyKey),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	swipe.ID = int(id)
+	swipe.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	return true, nil
+}
+
+// ResetSwipe replaces an existing swipe with a fresh one in the given
+// direction, as if it had just been made.
+func (r *Store) ResetSwipe(swipe *models.Swipe) error {
 	_, err := r.db.Exec(
-		"INSERT INTO swipes (user_id, item_id, direction) VALUES (?, ?, ?)",
-		swipe.UserID, swipe.ItemID, swipe.Direction,
+		"UPDATE swipes SET direction = ?, idempotency_key = ?, created_at = CURRENT_TIMESTAMP WHERE id = ?",
+		swipe.Direction, nullString(swipe.IdempotencyKey), swipe.ID,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	swipe.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	return nil
+}
+
+// CreateSwipeBatch records userID's swipes in order in one transaction and
+// returns one result per swipe. CreatedAt of each swipe is used as its time.
+// An existing swipe is only replaced if it is a left swipe made at least
+// cooldown before the new one; otherwise the swipe is reported as a duplicate
+// or, in the other direction, a conflict. Match detection is left to the
+// caller.
+func (r *Store) CreateSwipeBatch(userID int, swipes []models.Swipe, cooldown time.Duration) ([]models.SwipeResult, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]models.SwipeResult, len(swipes))
+	for i := range swipes {
+		swipe := swipes[i]
+		swipe.UserID = userID
+		result := &results[i]
+		result.ItemID = swipe.ItemID
+
+		existing, err := scanSwipe(tx.QueryRow(
+			"SELECT "+swipeColumns+" FROM swipes WHERE user_id = ? AND item_id = ?", userID, swipe.ItemID,
+		))
+		if err != nil {
+			return nil, err
+		}
+		resurfaced := existing != nil && existing.Direction == "left" && swipe.CreatedAt.Sub(existing.CreatedAt) >= cooldown
+		if existing != nil && !resurfaced {
+			result.Swipe = existing
+			result.Status = models.SwipeResultConflict
+			if existing.Direction == swipe.Direction {
+				result.Status = models.SwipeResultDuplicate
+			}
+			continue
+		}
+
+		var ownerID int
+		var status string
+		err = tx.QueryRow("SELECT user_id, status FROM items WHERE id = ?", swipe.ItemID).Scan(&ownerID, &status)
+		if err == sql.ErrNoRows || (err == nil && status != models.ItemStatusAvailable) {
+			result.Status = models.SwipeResultItemGone
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ownerID == userID {
+			result.Status = models.SwipeResultOwnItem
+			continue
+		}
+
+		createdAt := swipe.CreatedAt.UTC().Format(sqliteTimeLayout)
+		if existing != nil {
+			swipe.ID = existing.ID
+			_, err = tx.Exec(
+				"UPDATE swipes SET direction = ?, idempotency_key = NULL, created_at = ? WHERE id = ?",
+				swipe.Direction, createdAt, swipe.ID,
+			)
+		} else {
+			var res sql.Result
+			res, err = tx.Exec(
+				"INSERT INTO swipes (user_id, item_id, direction, created_at) VALUES (?, ?, ?, ?)",
+				userID, swipe.ItemID, swipe.Direction, createdAt,
+			)
+			if err == nil {
+				var id int64
+				id, err = res.LastInsertId()
+				swipe.ID = int(id)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		swipe.CreatedAt = swipe.CreatedAt.UTC().Truncate(time.Second)
+		result.Status = models.SwipeResultCreated
+		result.Swipe = &swipe
+	}
+
+	return results, tx.Commit()
+}
+
+const swipeColumns = `id, user_id, item_id, direction, COALESCE(idempotency_key, ''), created_at`
+
+func scanSwipe(row interface{ Scan(...interface{}) error }) (*models.Swipe, error) {
+	var swipe models.Swipe
+	err := row.Scan(&swipe.ID, &swipe.UserID, &swipe.ItemID, &swipe.Direction, &swipe.IdempotencyKey, &swipe.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &swipe, nil
+}
+
+// GetSwipe returns userID's swipe on itemID, or nil if they never swiped on it.
+func (r *Store) GetSwipe(userID, itemID int) (*models.Swipe, error) {
+	return scanSwipe(r.db.QueryRow("SELECT "+swipeColumns+" FROM swipes WHERE user_id = ? AND item_id = ?", userID, itemID))
+}
+
+// GetSwipeByKey returns the swipe userID made with an idempotency key, or nil.
+func (r *Store) GetSwipeByKey(userID int, key string) (*models.Swipe, error) {
+	return scanSwipe(r.db.QueryRow("SELECT "+swipeColumns+" FROM swipes WHERE user_id = ? AND idempotency_key = ?", userID, key))
+}
+
+// GetLastSwipe returns userID's most recent swipe, or nil if they have none.
+func (r *Store) GetLastSwipe(userID int) (*models.Swipe, error) {
+	return scanSwipe(r.db.QueryRow(
+		"SELECT "+swipeColumns+" FROM swipes WHERE user_id = ? ORDER BY created_at DESC, id DESC LIMIT 1",
+		userID,
+	))
+}
+
+// UndoSwipe deletes a swipe together with the matches that rest on it and,
+// for a super like, the owner's notification about it. It returns false
+// without writing anything if one of those matches has been commented on,
+// archived or moved on from proposed, or if the swipe is part of an open
+// ring trade.
+func (r *Store) UndoSwipe(swipe *models.Swipe) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// A match needs both users' right swipes, so any match pairing this
+	// user with this item on their side of the trade depends on it
+	const dependent = `
+		((user1_id = ? AND item2_id = ?) OR (user2_id = ? AND item1_id = ?))
+	`
+	args := []interface{}{swipe.UserID, swipe.ItemID, swipe.UserID, swipe.ItemID}
+
+	var busy int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM matches m
+		WHERE `+dependent+`
+		AND (m.status != ? OR m.archived_at IS NOT NULL
+			OR m.user1_confirmed_at IS NOT NULL OR m.user2_confirmed_at IS NOT NULL
+			OR EXISTS (SELECT 1 FROM comments c WHERE c.match_id = m.id))
+	`, append(args, models.MatchStatusProposed)...).Scan(&busy)
+	if err != nil {
+		return false, err
+	}
+	if busy > 0 {
+		return false, nil
+	}
+
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM group_match_participants p
+		JOIN group_matches g ON g.id = p.group_match_id
+		WHERE p.user_id = ? AND p.receives_item_id = ? AND g.status IN (?, ?)
+	`, swipe.UserID, swipe.ItemID, models.MatchStatusProposed, models.MatchStatusAccepted).Scan(&busy)
+	if err != nil {
+		return false, err
+	}
+	if busy > 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec("DELETE FROM matches WHERE "+dependent, args...); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("DELETE FROM swipes WHERE id = ?", swipe.ID); err != nil {
+		return false, err
+	}
+	if swipe.Direction == models.SwipeSuper {
+		if _, err := tx.Exec("DELETE FROM notifications WHERE type = ? AND actor_id = ? AND item_id = ?",
+			models.NotificationSuperLike, swipe.UserID, swipe.ItemID); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
 }
 
 func (r *Store) UserSwipedRight(userID, itemID int) (bool, error) {
@@ -19,7 +229,7 @@ func (r *Store) UserSwipedRight(userID, itemID int) (bool, error) {
 	err := r.db.QueryRow(`
 		SELECT COUNT(*) 
 		FROM swipes 
-		WHERE user_id = ? AND item_id = ? AND direction = 'right'
+		WHERE user_id = ? AND item_id = ? AND direction IN ('right', 'super')
 	`, userID, itemID).Scan(&count)
 	if err != nil {
 		return false, err
@@ -36,12 +246,12 @@ func (r *Store) CreateMatchIfNeeded(user1ID, user2ID, item1ID, item2ID int) erro
 	}
 	defer tx.Rollback()
 
-	// Check if user2 swiped right on item1
+	// Check if user2 swiped right (or super liked) item1
 	var count int
 	err = tx.QueryRow(`
 		SELECT COUNT(*) 
 		FROM swipes 
-		WHERE user_id = ? AND item_id = ? AND direction = 'right'
+		WHERE user_id = ? AND item_id = ? AND direction IN ('right', 'super')
 	`, user2ID, item1ID).Scan(&count)
 	if err != nil {
 		return err
@@ -50,6 +260,30 @@ func (r *Store) CreateMatchIfNeeded(user1ID, user2ID, item1ID, item2ID int) erro
 		return nil
 	}
 
+	// Users who blocked each other never match
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM blocks
+		WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+	`, user1ID, user2ID, user2ID, user1ID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	// Only items that are still available can be matched
+	err = tx.QueryRow(
+		"SELECT COUNT(*) FROM items WHERE id IN (?, ?) AND status = ?",
+		item1ID, item2ID, models.ItemStatusAvailable,
+	).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count < 2 {
+		return nil
+	}
+
 	// Check if match already exists
 	err = tx.QueryRow(`
 		SELECT COUNT(*) FROM matches 
@@ -76,23 +310,33 @@ func (r *Store) CreateMatchIfNeeded(user1ID, user2ID, item1ID, item2ID int) erro
 	return tx.Commit()
 }
 
-func (r *Store) GetMatches(userID int) ([]models.MatchResponse, error) {
-	query := `
+// GetMatches returns one page of userID's matches, newest first, and the
+// cursor of the next page.
+func (r *Store) GetMatches(userID int, page models.PageRequest) ([]models.MatchResponse, string, error) {
+	after, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	q := (&queryBuilder{}).where("(m.user1_id = ? OR m.user2_id = ?)", userID, userID)
+	q.where("m.archived_at IS NULL")
+	q.after("m", after, true)
+
+	suffix, suffixArgs := pageSuffix("m", page.Limit, true)
+	query, args := q.build(`
 		SELECT 
-			m.id, m.user1_id, m.user2_id, m.item1_id, m.item2_id, m.created_at,
-			i1.title, i2.title, u1.name, u2.name
+			m.id, m.user1_id, m.user2_id, m.item1_id, m.item2_id, m.status, m.created_at,
+			i1.title, i2.title, u1.name, u2.name, `+matchTimestampColumns+`
 		FROM matches m
 		JOIN items i1 ON m.item1_id = i1.id
 		JOIN items i2 ON m.item2_id = i2.id
 		JOIN users u1 ON m.user1_id = u1.id
 		JOIN users u2 ON m.user2_id = u2.id
-		WHERE m.user1_id = ? OR m.user2_id = ?
-		ORDER BY m.created_at DESC
-	`
+	`, suffix, suffixArgs...)
 
-	rows, err := r.db.Query(query, userID, userID)
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
@@ -100,20 +344,206 @@ func (r *Store) GetMatches(userID int) ([]models.MatchResponse, error) {
 	for rows.Next() {
 		var m models.MatchResponse
 		if err := rows.Scan(&m.ID, &m.User1ID, &m.User2ID, &m.Item1ID, &m.Item2ID,
-			&m.CreatedAt, &m.Item1Title, &m.Item2Title, &m.User1Name, &m.User2Name); err != nil {
-			return nil, err
+			&m.Status, &m.CreatedAt, &m.Item1Title, &m.Item2Title, &m.User1Name, &m.User2Name,
+			&m.AcceptedAt, &m.DeclinedAt, &m.CompletedAt, &m.CancelledAt,
+			&m.User1ConfirmedAt, &m.User2ConfirmedAt); err != nil {
+			return nil, "", err
 		}
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	rows.Close()
 
-		// Load comments for each match
-		comments, err := r.GetComments(m.ID)
+	matches, next := trimPage(matches, page.Limit, func(m models.MatchResponse) (time.Time, int) {
+		return m.CreatedAt, m.ID
+	})
+
+	// Load comments for each match once the match rows are released
+	for i := range matches {
+		comments, _, err := r.GetComments(matches[i].ID, models.PageRequest{})
 		if err == nil {
-			m.Comments = comments
+			matches[i].Comments = comments
 		}
+	}
 
-		matches = append(matches, m)
+	return matches, next, nil
+}
+
+func (r *Store) GetMatch(id int) (*models.Match, error) {
+	var m models.Match
+	err := r.db.QueryRow(`
+		SELECT m.id, m.user1_id, m.user2_id, m.item1_id, m.item2_id, m.status, m.created_at, `+matchTimestampColumns+`
+		FROM matches m WHERE m.id = ?
+	`, id).Scan(&m.ID, &m.User1ID, &m.User2ID, &m.Item1ID, &m.Item2ID, &m.Status, &m.CreatedAt,
+		&m.AcceptedAt, &m.DeclinedAt, &m.CompletedAt, &m.CancelledAt,
+		&m.User1ConfirmedAt, &m.User2ConfirmedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return matches, rows.Err()
+	return &m, nil
+}
+
+// matchTimestampColumns selects the transition timestamps of a match aliased
+// as m, in models.MatchTimestamps order.
+const matchTimestampColumns = `m.accepted_at, m.declined_at, m.completed_at, m.cancelled_at, m.user1_confirmed_at, m.user2_confirmed_at`
+
+// matchStatusColumns maps each match status reached by a transition to the
+// column recording when it happened.
+var matchStatusColumns = map[string]string{
+	models.MatchStatusAccepted:  "accepted_at",
+	models.MatchStatusDeclined:  "declined_at",
+	models.MatchStatusCompleted: "completed_at",
+	models.MatchStatusCancelled: "cancelled_at",
+}
+
+// TransitionMatch moves a match from one status to another, stamping when it
+// happened, and in the same transaction moves both of its items from itemFrom
+// to itemTo. An empty itemFrom leaves the items alone. Nothing is written and
+// false is returned if the match or either item is not in the expected status.
+func (r *Store) TransitionMatch(matchID int, from, to, itemFrom, itemTo string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if ok, err := transitionMatch(tx, matchID, from, to, itemFrom, itemTo); !ok || err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func transitionMatch(tx *sql.Tx, matchID int, from, to, itemFrom, itemTo string) (bool, error) {
+	column, ok := matchStatusColumns[to]
+	if !ok {
+		return false, fmt.Errorf("no timestamp for match status %q", to)
+	}
+
+	result, err := tx.Exec(
+		fmt.Sprintf("UPDATE matches SET status = ?, %s = CURRENT_TIMESTAMP WHERE id = ? AND status = ?", column),
+		to, matchID, from,
+	)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if itemFrom != "" {
+		result, err = tx.Exec(`
+			UPDATE items SET status = ?
+			WHERE status = ? AND id IN (SELECT item1_id FROM matches WHERE id = ? UNION SELECT item2_id FROM matches WHERE id = ?)
+		`, itemTo, itemFrom, matchID, matchID)
+		if err != nil {
+			return false, err
+		}
+		if n, err := result.RowsAffected(); err != nil || n != 2 {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// ArchiveMatch hides a match and its comments from both users. When to is
+// set, the match is first moved from its current status as TransitionMatch
+// would, except that items no longer in itemFrom are left alone.
+func (r *Store) ArchiveMatch(matchID int, from, to, itemFrom, itemTo string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if to != "" {
+		// Items are released on a best-effort basis: the match is going away either way
+		if _, err := transitionMatch(tx, matchID, from, to, "", ""); err != nil {
+			return err
+		}
+		if itemFrom != "" {
+			_, err := tx.Exec(`
+				UPDATE items SET status = ?
+				WHERE status = ? AND id IN (SELECT item1_id FROM matches WHERE id = ? UNION SELECT item2_id FROM matches WHERE id = ?)
+			`, itemTo, itemFrom, matchID, matchID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE matches SET archived_at = CURRENT_TIMESTAMP WHERE id = ? AND archived_at IS NULL", matchID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE comments SET archived_at = CURRENT_TIMESTAMP WHERE match_id = ? AND archived_at IS NULL", matchID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetOpenMatchesBetween returns the matches between two users that are not archived.
+func (r *Store) GetOpenMatchesBetween(user1ID, user2ID int) ([]models.Match, error) {
+	rows, err := r.db.Query(`
+		SELECT id FROM matches
+		WHERE archived_at IS NULL
+		AND ((user1_id = ? AND user2_id = ?) OR (user1_id = ? AND user2_id = ?))
+		ORDER BY id
+	`, user1ID, user2ID, user2ID, user1ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	matches := []models.Match{}
+	for _, id := range ids {
+		match, err := r.GetMatch(id)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, *match)
+	}
+	return matches, nil
+}
+
+// ConfirmMatch records that userID confirmed an accepted match's trade
+// happened. It returns false if the match is not accepted or the user had
+// already confirmed.
+func (r *Store) ConfirmMatch(matchID, userID int) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE matches SET
+			user1_confirmed_at = CASE WHEN user1_id = ? THEN CURRENT_TIMESTAMP ELSE user1_confirmed_at END,
+			user2_confirmed_at = CASE WHEN user2_id = ? THEN CURRENT_TIMESTAMP ELSE user2_confirmed_at END
+		WHERE id = ? AND status = ?
+			AND ((user1_id = ? AND user1_confirmed_at IS NULL) OR (user2_id = ? AND user2_confirmed_at IS NULL))
+	`, userID, userID, matchID, models.MatchStatusAccepted, userID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
 }
 
 func (r *Store) MatchExists(user1ID, user2ID, item1ID, item2ID int) (bool, error) {
@@ -135,7 +565,7 @@ func (r *Store) UserInMatch(matchID, userID int) (bool, error) {
 	var count int
 	err := r.db.QueryRow(`
 		SELECT COUNT(*) FROM matches 
-		WHERE id = ? AND (user1_id = ? OR user2_id = ?)
+		WHERE id = ? AND (user1_id = ? OR user2_id = ?) AND archived_at IS NULL
 	`, matchID, userID, userID).Scan(&count)
 
 	if err != nil {
@@ -143,4 +573,158 @@ func (r *Store) UserInMatch(matchID, userID int) (bool, error) {
 	}
 
 	return count > 0, nil
-}
\ No newline at end of file
+}
+
+// GetSwipeTime returns when userID swiped on itemID, or nil if they never did.
+func (r *Store) GetSwipeTime(userID, itemID int) (*time.Time, error) {
+	var swipedAt time.Time
+	err := r.db.QueryRow(
+		"SELECT created_at FROM swipes WHERE user_id = ? AND item_id = ?",
+		userID, itemID,
+	).Scan(&swipedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &swipedAt, nil
+}
+
+// GetSuperLikerItems returns up to limit items owned by users who super
+// liked one of userID's items, most recent super like first, under the same
+// feed rules and filter as GetItems.
+func (r *Store) GetSuperLikerItems(userID int, filter models.ItemFilter, limit int) ([]models.ItemWithOwner, error) {
+	q := &queryBuilder{}
+	applyFeedRules(q, userID, filter)
+
+	query, args := q.build(`
+		SELECT `+itemColumns+`, u.name, `+feedLocationColumns+`
+		FROM (
+			SELECT s.user_id, MAX(s.created_at) AS liked_at
+			FROM swipes s JOIN items mine ON mine.id = s.item_id
+			WHERE s.direction = 'super' AND mine.user_id = ?
+			GROUP BY s.user_id
+		) likers
+		JOIN items i ON i.user_id = likers.user_id
+		JOIN users u ON i.user_id = u.id
+	`, " ORDER BY likers.liked_at DESC, i.created_at DESC, i.id DESC")
+	args = append([]interface{}{userID}, args...)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ItemWithOwner{}
+	for rows.Next() {
+		var item models.ItemWithOwner
+		var loc itemLocation
+
+		dest := append([]interface{}{&item.OwnerName}, loc.dest()...)
+		if err := scanItem(rows, &item.Item, dest...); err != nil {
+			return nil, err
+		}
+
+		if !nearby(&item, loc, filter) {
+			continue
+		}
+		item.OwnerSuperLiked = true
+		items = append(items, item)
+		if len(items) == limit {
+			break
+		}
+	}
+
+	return items, rows.Err()
+}
+
+// oneSidedLikers selects the users who right swiped one of the viewer's
+// available items while the viewer has liked none of theirs. Users who super
+// liked the viewer are left out, since they chose to be seen, as are users
+// the viewer blocked. It takes the viewer's id four times.
+const oneSidedLikers = `
+	SELECT s.user_id, MAX(s.created_at) AS liked_at
+	FROM swipes s
+	JOIN items mine ON mine.id = s.item_id
+	WHERE mine.user_id = ? AND mine.status = 'available' AND s.direction = 'right'
+	AND NOT EXISTS (
+		SELECT 1 FROM swipes back JOIN items theirs ON theirs.id = back.item_id
+		WHERE back.user_id = mine.user_id AND theirs.user_id = s.user_id AND back.direction IN ('right', 'super')
+	)
+	AND s.user_id NOT IN (
+		SELECT sl.user_id FROM swipes sl JOIN items liked ON liked.id = sl.item_id
+		WHERE liked.user_id = ? AND sl.direction = 'super'
+	)
+	AND s.user_id NOT IN (SELECT blocked_id FROM blocks WHERE blocker_id = ?)
+	AND s.user_id != ?
+	GROUP BY s.user_id
+`
+
+// GetOneSidedLikers returns the users whose like on one of userID's items
+// is not yet returned.
+func (r *Store) GetOneSidedLikers(userID int) (map[int]bool, error) {
+	rows, err := r.db.Query(oneSidedLikers, userID, userID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	likers := map[int]bool{}
+	for rows.Next() {
+		var id int
+		var likedAt interface{}
+		if err := rows.Scan(&id, &likedAt); err != nil {
+			return nil, err
+		}
+		likers[id] = true
+	}
+
+	return likers, rows.Err()
+}
+
+// GetInterestHintItems returns up to limit items of one-sided likers of
+// userID's items, most recent like first, under the same feed rules and
+// filter as GetItems.
+func (r *Store) GetInterestHintItems(userID int, filter models.ItemFilter, limit int) ([]models.ItemWithOwner, error) {
+	q := &queryBuilder{}
+	applyFeedRules(q, userID, filter)
+
+	query, args := q.build(`
+		SELECT `+itemColumns+`, u.name, `+feedLocationColumns+`
+		FROM (`+oneSidedLikers+`) likers
+		JOIN items i ON i.user_id = likers.user_id
+		JOIN users u ON i.user_id = u.id
+	`, " ORDER BY likers.liked_at DESC, i.created_at DESC, i.id DESC")
+	args = append([]interface{}{userID, userID, userID, userID}, args...)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ItemWithOwner{}
+	for rows.Next() {
+		var item models.ItemWithOwner
+		var loc itemLocation
+
+		dest := append([]interface{}{&item.OwnerName}, loc.dest()...)
+		if err := scanItem(rows, &item.Item, dest...); err != nil {
+			return nil, err
+		}
+
+		if !nearby(&item, loc, filter) {
+			continue
+		}
+		items = append(items, item)
+		if len(items) == limit {
+			break
+		}
+	}
+
+	return items, rows.Err()
+}
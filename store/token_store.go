@@ -0,0 +1,72 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/notLeoHirano/bartr/models"
+)
+
+const refreshTokenColumns = `id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at`
+
+func createRefreshToken(tx *sql.Tx, token *models.RefreshToken) error {
+	result, err := tx.Exec(
+		"INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)",
+		token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt.UTC().Format(sqliteTimeLayout),
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	token.ID = int(id)
+	return nil
+}
+
+// GetRefreshToken looks a refresh token up by its hash.
+func (r *Store) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.QueryRow(
+		"SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE token_hash = ?",
+		tokenHash,
+	).Scan(&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &token.ExpiresAt,
+		&token.UsedAt, &token.RevokedAt, &token.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken marks old as used and stores next in its place, in one
+// transaction. It reports false, storing nothing, when old was already used
+// or revoked, so two concurrent refreshes cannot both succeed.
+func (r *Store) RotateRefreshToken(old, next *models.RefreshToken) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL",
+		time.Now().UTC().Format(sqliteTimeLayout), old.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if err := createRefreshToken(tx, next); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"database/sql"
+
+	"github.com/notLeoHirano/bartr/models"
+)
+
+// wantColumns selects a want from rows aliased as w, with its category
+// joined as wc, in scanWant order.
+const wantColumns = `w.id, w.user_id, w.keywords, COALESCE(wc.name, ''), w.category_id, w.max_distance_km, w.created_at`
+
+func wantDest(want *models.Want) []interface{} {
+	return []interface{}{&want.ID, &want.UserID, &want.Keywords, &want.Category,
+		&want.CategoryID, &want.MaxDistanceKm, &want.CreatedAt}
+}
+
+func scanWant(scanner interface{ Scan(...interface{}) error }, want *models.Want, extra ...interface{}) error {
+	return scanner.Scan(append(wantDest(want), extra...)...)
+}
+
+func (r *Store) CreateWant(want *models.Want) error {
+	result, err := r.db.Exec(
+		"INSERT INTO wants (user_id, keywords, category_id, max_distance_km) VALUES (?, ?, ?, ?)",
+		want.UserID, want.Keywords, want.CategoryID, want.MaxDistanceKm,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	want.ID = int(id)
+	return nil
+}
+
+func (r *Store) GetWants(userID int) ([]models.Want, error) {
+	rows, err := r.db.Query(`
+		SELECT `+wantColumns+`
+		FROM wants w
+		LEFT JOIN categories wc ON wc.id = w.category_id
+		WHERE w.user_id = ?
+		ORDER BY w.created_at DESC, w.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wants := []models.Want{}
+	for rows.Next() {
+		var want models.Want
+		if err := scanWant(rows, &want); err != nil {
+			return nil, err
+		}
+		wants = append(wants, want)
+	}
+
+	return wants, rows.Err()
+}
+
+func (r *Store) CountWants(userID int) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM wants WHERE user_id = ?", userID).Scan(&count)
+	return count, err
+}
+
+// DeleteWant removes a want owned by userID together with its suggestions.
+func (r *Store) DeleteWant(id int, userID int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM wants WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM want_suggestions WHERE want_id = ?", id); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// CandidateWant is a want that may match an item, with where its owner is.
+type CandidateWant struct {
+	models.Want
+	OwnerLocation *models.Location
+}
+
+// GetCandidateWants returns the wants of users other than the item's owner
+// whose category is empty or is the item's category or one of its ancestors.
+// Keywords and distance are left to the caller.
+func (r *Store) GetCandidateWants(item *models.Item) ([]CandidateWant, error) {
+	rows, err := r.db.Query(`
+		SELECT `+wantColumns+`, u.latitude, u.longitude
+		FROM wants w
+		LEFT JOIN categories wc ON wc.id = w.category_id
+		JOIN users u ON u.id = w.user_id
+		WHERE w.user_id != ? AND (w.category_id IS NULL OR w.category_id IN (
+			WITH RECURSIVE up(id) AS (
+				SELECT ?
+				UNION
+				SELECT c.parent_id FROM categories c JOIN up ON c.id = up.id WHERE c.parent_id IS NOT NULL
+			)
+			SELECT id FROM up
+		))
+		ORDER BY w.id
+	`, item.UserID, item.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []CandidateWant{}
+	for rows.Next() {
+		var c CandidateWant
+		var lat, lng sql.NullFloat64
+		if err := scanWant(rows, &c.Want, &lat, &lng); err != nil {
+			return nil, err
+		}
+		if lat.Valid && lng.Valid {
+			c.OwnerLocation = &models.Location{Latitude: lat.Float64, Longitude: lng.Float64}
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// ItemMatchesText reports whether an item's indexed text matches an FTS5
+// match expression.
+func (r *Store) ItemMatchesText(itemID int, match string) (bool, error) {
+	var count int
+	err := r.db.QueryRow(
+		"SELECT COUNT(*) FROM items_fts WHERE rowid = ? AND items_fts MATCH ?",
+		itemID, match,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// GetItemLocation returns where an item is: its own position, or else its
+// owner's. It returns nil when neither is known.
+func (r *Store) GetItemLocation(itemID int) (*models.Location, error) {
+	var lat, lng sql.NullFloat64
+	err := r.db.QueryRow(`
+		SELECT COALESCE(i.latitude, u.latitude), COALESCE(i.longitude, u.longitude)
+		FROM items i JOIN users u ON u.id = i.user_id
+		WHERE i.id = ?
+	`, itemID).Scan(&lat, &lng)
+	if err != nil {
+		return nil, err
+	}
+
+	if !lat.Valid || !lng.Valid {
+		return nil, nil
+	}
+	return &models.Location{Latitude: lat.Float64, Longitude: lng.Float64}, nil
+}
+
+func (r *Store) CreateWantSuggestion(wantID, itemID, userID int) error {
+	_, err := r.db.Exec(
+		"INSERT OR IGNORE INTO want_suggestions (want_id, item_id, user_id) VALUES (?, ?, ?)",
+		wantID, itemID, userID,
+	)
+	return err
+}
+
+// GetSuggestedItems returns up to limit items suggested to userID by their
+// wants, newest suggestion first, under the same feed rules and filter as
+// GetItems. An item matching several wants is listed once, for the earliest.
+func (r *Store) GetSuggestedItems(userID int, filter models.ItemFilter, limit int) ([]models.ItemWithOwner, error) {
+	q := &queryBuilder{}
+	applyFeedRules(q, userID, filter)
+
+	query, args := q.build(`
+		SELECT `+itemColumns+`, u.name, `+feedLocationColumns+`, `+wantColumns+`
+		FROM (
+			SELECT item_id, MIN(id) AS id FROM want_suggestions WHERE user_id = ? GROUP BY item_id
+		) first
+		JOIN want_suggestions s ON s.id = first.id
+		JOIN wants w ON w.id = s.want_id
+		LEFT JOIN categories wc ON wc.id = w.category_id
+		JOIN items i ON i.id = s.item_id
+		JOIN users u ON i.user_id = u.id
+	`, " ORDER BY s.created_at DESC, s.id DESC")
+	args = append([]interface{}{userID}, args...)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ItemWithOwner{}
+	for rows.Next() {
+		var item models.ItemWithOwner
+		var loc itemLocation
+		var want models.Want
+
+		dest := append([]interface{}{&item.OwnerName}, loc.dest()...)
+		dest = append(dest, wantDest(&want)...)
+		if err := scanItem(rows, &item.Item, dest...); err != nil {
+			return nil, err
+		}
+
+		if !nearby(&item, loc, filter) {
+			continue
+		}
+		item.SuggestedFor = &want
+		items = append(items, item)
+		if len(items) == limit {
+			break
+		}
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,190 @@
+// Package tradegraph finds multi-party trades in the graph of right swipes.
+//
+// Every right swipe is an edge from the swiping user to the owner of the
+// item they liked. A cycle A→B→C→A is a ring trade: A receives B's item, B
+// receives C's item and C receives A's item, so everyone gets something they
+// asked for even though no two of them like each other's items.
+package tradegraph
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxCycles bounds how many cycles FindCycles enumerates, since dense graphs
+// have exponentially many.
+const MaxCycles = 10000
+
+// Edge records that From wants Item, which is owned by To.
+type Edge struct {
+	From int
+	To   int
+	Item int
+}
+
+// Cycle is a ring trade. Edge i ends where edge i+1 starts, and the last
+// edge ends where the first starts. Each user receives the item on their
+// outgoing edge and gives the item on their incoming edge.
+type Cycle []Edge
+
+// Users returns the participants in trade order.
+func (c Cycle) Users() []int {
+	users := make([]int, len(c))
+	for i, e := range c {
+		users[i] = e.From
+	}
+	return users
+}
+
+// Gives returns the item the participant at position i hands over: the one
+// the previous participant wants.
+func (c Cycle) Gives(i int) int {
+	return c[(i+len(c)-1)%len(c)].Item
+}
+
+// Key identifies a cycle by the items that change hands, regardless of
+// where it starts.
+func (c Cycle) Key() string {
+	items := make([]int, len(c))
+	for i, e := range c {
+		items[i] = e.Item
+	}
+	sort.Ints(items)
+
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = strconv.Itoa(item)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (c Cycle) String() string {
+	parts := make([]string, len(c))
+	for i, e := range c {
+		parts[i] = fmt.Sprintf("%d-[%d]->", e.From, e.Item)
+	}
+	return strings.Join(parts, "") + strconv.Itoa(c[0].From)
+}
+
+// FindCycles returns every simple cycle with between minLen and maxLen
+// participants. When a user likes several items of the same owner, only the
+// lowest item id is used, so the output depends only on the set of edges and
+// not on their order. Each cycle starts at its lowest user id, and cycles
+// are sorted by length, then by users, then by items.
+func FindCycles(edges []Edge, minLen, maxLen int) []Cycle {
+	adjacency := buildAdjacency(edges)
+
+	starts := make([]int, 0, len(adjacency))
+	for user := range adjacency {
+		starts = append(starts, user)
+	}
+	sort.Ints(starts)
+
+	var cycles []Cycle
+	path := make(Cycle, 0, maxLen)
+	onPath := map[int]bool{}
+
+	var visit func(start, user int)
+	visit = func(start, user int) {
+		for _, e := range adjacency[user] {
+			if len(cycles) >= MaxCycles {
+				return
+			}
+			if e.To == start {
+				if n := len(path) + 1; n >= minLen && n <= maxLen {
+					cycles = append(cycles, append(append(Cycle{}, path...), e))
+				}
+				continue
+			}
+			// Only users above start, so each cycle is found once, from its lowest user
+			if e.To < start || onPath[e.To] || len(path)+1 >= maxLen {
+				continue
+			}
+
+			path = append(path, e)
+			onPath[e.To] = true
+			visit(start, e.To)
+			onPath[e.To] = false
+			path = path[:len(path)-1]
+		}
+	}
+
+	for _, start := range starts {
+		onPath[start] = true
+		visit(start, start)
+		onPath[start] = false
+	}
+
+	sort.SliceStable(cycles, func(i, j int) bool { return less(cycles[i], cycles[j]) })
+	return cycles
+}
+
+// SelectDisjoint picks cycles in order, skipping any that share a user with
+// one already picked, so nobody is offered two trades at once.
+func SelectDisjoint(cycles []Cycle) []Cycle {
+	used := map[int]bool{}
+	var selected []Cycle
+
+	for _, c := range cycles {
+		free := true
+		for _, user := range c.Users() {
+			if used[user] {
+				free = false
+				break
+			}
+		}
+		if !free {
+			continue
+		}
+
+		for _, user := range c.Users() {
+			used[user] = true
+		}
+		selected = append(selected, c)
+	}
+
+	return selected
+}
+
+// buildAdjacency keeps one edge per (from, to) pair, the lowest item, and
+// sorts each user's edges by target.
+func buildAdjacency(edges []Edge) map[int][]Edge {
+	best := map[[2]int]Edge{}
+	for _, e := range edges {
+		if e.From == e.To {
+			continue
+		}
+		key := [2]int{e.From, e.To}
+		if current, ok := best[key]; !ok || e.Item < current.Item {
+			best[key] = e
+		}
+	}
+
+	adjacency := map[int][]Edge{}
+	for _, e := range best {
+		adjacency[e.From] = append(adjacency[e.From], e)
+	}
+	for _, out := range adjacency {
+		sort.Slice(out, func(i, j int) bool { return out[i].To < out[j].To })
+	}
+	return adjacency
+}
+
+func less(a, b Cycle) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	for i := range a {
+		if a[i].From != b[i].From {
+			return a[i].From < b[i].From
+		}
+	}
+	for i := range a {
+		if a[i].Item != b[i].Item {
+			return a[i].Item < b[i].Item
+		}
+	}
+	return false
+}